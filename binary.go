@@ -0,0 +1,461 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+)
+
+// The binary format is a compact and quick to read alternative to s-expression text.
+//
+// A stream starts with a header made of binaryMagic and the version, as a uvarint.
+// Then come the Trees, each in a frame made of its length in bytes, as a uvarint, and the encoded Tree.
+//
+// A Tree is encoded as a uvarint tag saying what it is, followed by
+//
+//   - nothing, for an invalid Tree
+//   - the varint value, for an int
+//   - the IEEE 754 bits in little endian order, for a float
+//   - a big integer, for a big integer
+//   - two big integers for the numerator and the denominator, for a rational
+//   - the uvarint length in bytes and the bytes, for a string or a symbol
+//   - the uvarint index in the symbol table, for a symbol already in there
+//   - the uvarint number of elements and the elements, for a list
+//   - the same and the tail, for an improper list
+//
+// A big integer is encoded as a uvarint twice its length in bytes, plus one when it is negative,
+// followed by the bytes of its absolute value in big-endian order.
+//
+// The symbol table is shared by all the Trees in the stream.
+// Symbols are added to it the first time they appear, until it has maxBinarySymbols entries.
+// After that, symbols that are not in the table are written out in full every time.
+
+const (
+	binaryMagic   = "symT"
+	binaryVersion = 1
+)
+
+const (
+	binaryInvalid = iota
+	binaryInt
+	binaryFloat
+	binaryBigInt
+	binaryRat
+	binaryString
+	binarySymbol
+	binaryNewSymbol
+	binarySymbolRef
+	binaryList
+	binaryImproperList
+)
+
+// maxBinarySymbols limits the size of the symbol table.
+const maxBinarySymbols = 1 << 16
+
+// A BinaryEncoder writes a sequence of Trees to an output stream in binary form.
+// A BinaryDecoder reads them back.
+type BinaryEncoder struct {
+	dst     io.Writer
+	started bool
+	symbols map[string]int
+	frame   []byte
+	buf     []byte
+	err     error
+}
+
+// NewBinaryEncoder creates a BinaryEncoder that writes to dst.
+// The header is written along with the first Tree.
+func NewBinaryEncoder(dst io.Writer) *BinaryEncoder {
+	return &BinaryEncoder{dst: dst, symbols: map[string]int{}}
+}
+
+// Encode writes t to the output.
+// Each Tree is written with a single call to the Write method of the output.
+// Spans are not kept.
+//
+// Once a write fails, the output can't be decoded past that point,
+// as later Trees could refer to symbols written only in the failed one.
+// So all further calls to Encode fail the same way.
+func (e *BinaryEncoder) Encode(t Tree) error {
+	if e.err != nil {
+		return e.err
+	}
+	e.frame = e.appendTree(e.frame[:0], t)
+	e.buf = e.buf[:0]
+	if !e.started {
+		e.buf = binary.AppendUvarint(append(e.buf, binaryMagic...), binaryVersion)
+	}
+	e.buf = append(binary.AppendUvarint(e.buf, uint64(len(e.frame))), e.frame...)
+	if _, err := e.dst.Write(e.buf); err != nil {
+		e.err = err
+		return err
+	}
+	e.started = true
+	return nil
+}
+
+func (e *BinaryEncoder) appendTree(dst []byte, t Tree) []byte {
+	switch t.tag {
+	case symTreeInvalid:
+		return binary.AppendUvarint(dst, binaryInvalid)
+	case symTreeSymbol:
+		return e.appendSymbol(dst, t.symbol)
+	case symTreeNumber:
+		return binary.AppendVarint(binary.AppendUvarint(dst, binaryInt), int64(t.number))
+	case symTreeFloat:
+		return binary.LittleEndian.AppendUint64(binary.AppendUvarint(dst, binaryFloat), math.Float64bits(t.float))
+	case symTreeBigInt:
+		return appendBigInt(binary.AppendUvarint(dst, binaryBigInt), t.bigInt)
+	case symTreeRat:
+		dst = appendBigInt(binary.AppendUvarint(dst, binaryRat), t.rat.Num())
+		return appendBigInt(dst, t.rat.Denom())
+	case symTreeString:
+		return appendBinaryText(binary.AppendUvarint(dst, binaryString), t.symbol)
+	}
+	tail, improper := t.list.Tail()
+	tag := uint64(binaryList)
+	if improper {
+		tag = binaryImproperList
+	}
+	dst = binary.AppendUvarint(binary.AppendUvarint(dst, tag), uint64(t.list.Len()))
+	for _, elem := range t.list.All() {
+		dst = e.appendTree(dst, elem)
+	}
+	if improper {
+		dst = e.appendTree(dst, tail)
+	}
+	return dst
+}
+
+func (e *BinaryEncoder) appendSymbol(dst []byte, name string) []byte {
+	if index, ok := e.symbols[name]; ok {
+		return binary.AppendUvarint(binary.AppendUvarint(dst, binarySymbolRef), uint64(index))
+	}
+	if len(e.symbols) == maxBinarySymbols {
+		return appendBinaryText(binary.AppendUvarint(dst, binarySymbol), name)
+	}
+	e.symbols[name] = len(e.symbols)
+	return appendBinaryText(binary.AppendUvarint(dst, binaryNewSymbol), name)
+}
+
+func appendBinaryText(dst []byte, text string) []byte {
+	return append(binary.AppendUvarint(dst, uint64(len(text))), text...)
+}
+
+func appendBigInt(dst []byte, n *big.Int) []byte {
+	abs := n.Bytes()
+	header := uint64(len(abs)) << 1
+	if n.Sign() < 0 {
+		header |= 1
+	}
+	return append(binary.AppendUvarint(dst, header), abs...)
+}
+
+// MarshalBinary encodes a Tree in binary form.
+// The result is the same as what a new BinaryEncoder would write for the Tree alone.
+func (tree Tree) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := NewBinaryEncoder(&buf).Encode(tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalBinary decodes a Tree written by MarshalBinary.
+// The data must hold exactly one Tree.
+func (tree *Tree) UnmarshalBinary(data []byte) error {
+	src := bytes.NewReader(data)
+	t, err := NewBinaryDecoder(src).Decode()
+	if err == io.EOF {
+		err = fmt.Errorf("invalid binary Tree: %w", io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return err
+	}
+	if src.Len() > 0 {
+		return errors.New("invalid binary Tree: there's data left after the Tree")
+	}
+	*tree = t
+	return nil
+}
+
+// A BinaryDecoder reads a sequence of Trees in binary form from an input stream.
+type BinaryDecoder struct {
+	src     byteReader
+	started bool
+	symbols []string
+	frame   bytes.Buffer
+	err     error
+}
+
+// NewBinaryDecoder creates a BinaryDecoder that reads from src.
+// The BinaryDecoder buffers src, unless it is an io.ByteReader already.
+// It then reads nothing past the end of the last Tree it decodes.
+func NewBinaryDecoder(src io.Reader) *BinaryDecoder {
+	br, ok := src.(byteReader)
+	if !ok {
+		br = bufio.NewReader(src)
+	}
+	return &BinaryDecoder{src: br}
+}
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// Decode reads the next Tree from the input.
+//
+// When there are no Trees left, the error is io.EOF.
+// When the input ends in the middle of a Tree, the error wraps io.ErrUnexpectedEOF.
+// Lists nested more than 10000 levels deep are an error as well, to keep untrusted input from exhausting the stack.
+// After an error, all further calls to Decode fail the same way.
+func (d *BinaryDecoder) Decode() (Tree, error) {
+	if d.err != nil {
+		return Tree{}, d.err
+	}
+	t, err := d.decode()
+	d.err = err
+	return t, err
+}
+
+func (d *BinaryDecoder) decode() (Tree, error) {
+	if !d.started {
+		if err := d.readHeader(); err != nil {
+			return Tree{}, err
+		}
+		d.started = true
+	}
+
+	size, err := binary.ReadUvarint(d.src)
+	if err == io.EOF {
+		return Tree{}, io.EOF
+	}
+	if err != nil {
+		return Tree{}, binaryError(orUnexpectedEOF(err))
+	}
+	if size > math.MaxInt64 {
+		return Tree{}, binaryError(errors.New("the frame is too long"))
+	}
+	d.frame.Reset()
+	if _, err := io.CopyN(&d.frame, d.src, int64(size)); err != nil {
+		return Tree{}, binaryError(orUnexpectedEOF(err))
+	}
+
+	fr := frameReader{data: d.frame.Bytes(), symbols: d.symbols}
+	t := fr.tree()
+	d.symbols = fr.symbols
+	if fr.err == nil && fr.pos != len(fr.data) {
+		fr.err = errors.New("there's data left in the frame")
+	}
+	if fr.err != nil {
+		return Tree{}, binaryError(fr.err)
+	}
+	return t, nil
+}
+
+func (d *BinaryDecoder) readHeader() error {
+	magic := make([]byte, len(binaryMagic))
+	for i := range magic {
+		b, err := d.src.ReadByte()
+		if err == io.EOF && i == 0 {
+			return io.EOF
+		}
+		if err != nil {
+			return binaryError(orUnexpectedEOF(err))
+		}
+		magic[i] = b
+	}
+	if string(magic) != binaryMagic {
+		return binaryError(errors.New("the header is missing"))
+	}
+	version, err := binary.ReadUvarint(d.src)
+	if err != nil {
+		return binaryError(orUnexpectedEOF(err))
+	}
+	if version != binaryVersion {
+		return binaryError(fmt.Errorf("unsupported version %d", version))
+	}
+	return nil
+}
+
+func binaryError(err error) error {
+	return fmt.Errorf("invalid binary Tree: %w", err)
+}
+
+// orUnexpectedEOF turns io.EOF into io.ErrUnexpectedEOF, for when the input ends in the middle of something.
+func orUnexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// A frameReader decodes a Tree from a frame.
+// Errors are sticky, like those of the s-expression reader.
+type frameReader struct {
+	data    []byte
+	pos     int
+	symbols []string
+	depth   int
+	err     error
+}
+
+// maxBinaryDepth limits how deeply lists can be nested in the input of a BinaryDecoder.
+// Without it, untrusted input could make decoding run out of stack.
+const maxBinaryDepth = 10000
+
+func (fr *frameReader) tree() Tree {
+	switch tag := fr.uvarint(); tag {
+	case binaryInvalid:
+		return Tree{}
+	case binaryInt:
+		n := fr.varint()
+		if n < math.MinInt || n > math.MaxInt {
+			return BigInt(big.NewInt(n))
+		}
+		return Num(int(n))
+	case binaryFloat:
+		bits := fr.bytes(8)
+		if bits == nil {
+			return Tree{}
+		}
+		return Float(math.Float64frombits(binary.LittleEndian.Uint64(bits)))
+	case binaryBigInt:
+		return BigInt(fr.bigInt())
+	case binaryRat:
+		num, denom := fr.bigInt(), fr.bigInt()
+		if fr.err == nil && denom.Sign() == 0 {
+			fr.setErr(errors.New("a rational has a zero denominator"))
+		}
+		if fr.err != nil {
+			return Tree{}
+		}
+		return Rat(new(big.Rat).SetFrac(num, denom))
+	case binaryString:
+		return Str(fr.text())
+	case binarySymbol:
+		return Sym(fr.text())
+	case binaryNewSymbol:
+		name := fr.text()
+		if fr.err == nil && len(fr.symbols) == maxBinarySymbols {
+			fr.setErr(errors.New("the symbol table is full"))
+		}
+		fr.symbols = append(fr.symbols, name)
+		return Sym(name)
+	case binarySymbolRef:
+		index := fr.uvarint()
+		if fr.err == nil && index >= uint64(len(fr.symbols)) {
+			fr.setErr(fmt.Errorf("no symbol at index %d", index))
+		}
+		if fr.err != nil {
+			return Tree{}
+		}
+		return Sym(fr.symbols[index])
+	case binaryList, binaryImproperList:
+		return fr.list(tag == binaryImproperList)
+	default:
+		fr.setErr(fmt.Errorf("unknown tag %d", tag))
+		return Tree{}
+	}
+}
+
+func (fr *frameReader) list(improper bool) Tree {
+	if fr.depth == maxBinaryDepth {
+		fr.setErr(fmt.Errorf("lists are nested more than %d levels deep", maxBinaryDepth))
+		return Tree{}
+	}
+	fr.depth++
+	defer func() { fr.depth-- }()
+
+	n := fr.uvarint()
+	// Each element takes at least a byte, so a bogus length can't make us allocate too much.
+	elems := make([]Tree, 0, min(n, uint64(len(fr.data)-fr.pos)))
+	for i := uint64(0); i < n && fr.err == nil; i++ {
+		elems = append(elems, fr.tree())
+	}
+	if !improper {
+		return Lst(elems...)
+	}
+	tail := fr.tree()
+	if fr.err == nil && (n == 0 || tail.tag == symTreeInvalid || tail.tag == symTreeList) {
+		fr.setErr(errors.New("an improper list needs elements and a tail that is not a list"))
+	}
+	return DottedLst(elems, tail)
+}
+
+func (fr *frameReader) bigInt() *big.Int {
+	header := fr.uvarint()
+	abs := fr.bytes(header >> 1)
+	n := new(big.Int).SetBytes(abs)
+	if header&1 == 1 {
+		n.Neg(n)
+	}
+	return n
+}
+
+func (fr *frameReader) text() string {
+	return string(fr.bytes(fr.uvarint()))
+}
+
+func (fr *frameReader) bytes(n uint64) []byte {
+	if fr.err != nil {
+		return nil
+	}
+	if n > uint64(len(fr.data)-fr.pos) {
+		fr.setErr(io.ErrUnexpectedEOF)
+		return nil
+	}
+	b := fr.data[fr.pos : fr.pos+int(n)]
+	fr.pos += int(n)
+	return b
+}
+
+func (fr *frameReader) uvarint() uint64 {
+	if fr.err != nil {
+		return 0
+	}
+	x, n := binary.Uvarint(fr.data[fr.pos:])
+	if n <= 0 {
+		fr.setErr(varintError(n))
+		return 0
+	}
+	fr.pos += n
+	return x
+}
+
+func (fr *frameReader) varint() int64 {
+	if fr.err != nil {
+		return 0
+	}
+	x, n := binary.Varint(fr.data[fr.pos:])
+	if n <= 0 {
+		fr.setErr(varintError(n))
+		return 0
+	}
+	fr.pos += n
+	return x
+}
+
+// varintError explains why binary.Uvarint or binary.Varint failed, given what they returned.
+func varintError(n int) error {
+	if n == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return errors.New("a varint overflows 64 bits")
+}
+
+func (fr *frameReader) setErr(err error) {
+	if fr.err == nil {
+		fr.err = err
+	}
+}
@@ -0,0 +1,292 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestBinaryRoundTrip(t *testing.T) {
+	trees := map[string]Tree{
+		"invalid":        {},
+		"symbol":         Sym("foo"),
+		"emptySymbol":    Sym(""),
+		"number":         Num(-300),
+		"largestNumber":  Num(math.MaxInt),
+		"float":          Float(-2.5),
+		"negativeZero":   Float(math.Copysign(0, -1)),
+		"nan":            Float(math.NaN()),
+		"bigInt":         BigInt(hugeInt()),
+		"negativeBigInt": BigInt(new(big.Int).Neg(hugeInt())),
+		"rat":            Rat(big.NewRat(-7, 3)),
+		"bigRat":         Rat(new(big.Rat).SetFrac(hugeInt(), big.NewInt(7))),
+		"string":         Str("\x00 bytes \xff"),
+		"emptyList":      Lst(),
+		"list":           Lst(Sym("a"), Lst(Sym("a"), Str("a")), Sym("b"), Sym("a")),
+		"improperList":   DottedLst([]Tree{Sym("a"), Num(1)}, Sym("a")),
+		"invalidInList":  Lst(Tree{}),
+	}
+	for name, tree := range trees {
+		data, err := tree.MarshalBinary()
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+		var back Tree
+		err = back.UnmarshalBinary(data)
+		assert(t.Errorf, err == nil && Equal(back, tree), "%s: expected %v back, got %v, %v", name, tree, back, err)
+		assert(t.Errorf, back.tag == tree.tag, "%s: the shape changed from %v to %v", name, tree.tag, back.tag)
+		if tree.tag == symTreeFloat {
+			same := math.Float64bits(back.float) == math.Float64bits(tree.float)
+			assert(t.Errorf, same, "%s: the bits of the float changed from %x to %x", name, math.Float64bits(tree.float), math.Float64bits(back.float))
+		}
+	}
+}
+
+func TestBinarySymbolTable(t *testing.T) {
+	name := strings.Repeat("long-symbol-name", 4)
+	repeated := make([]Tree, 100)
+	for i := range repeated {
+		repeated[i] = Sym(name)
+	}
+	data, _ := Lst(repeated...).MarshalBinary()
+	assert(t.Errorf, len(data) < 4*len(repeated), "expected repeated symbols to be stored once, got %d bytes", len(data))
+
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	enc.Encode(Sym(name))
+	before := buf.Len()
+	enc.Encode(Sym(name))
+	assert(t.Errorf, buf.Len()-before < 4, "expected the symbol table to be shared between Trees, the second took %d bytes", buf.Len()-before)
+}
+
+func TestBinaryFullSymbolTable(t *testing.T) {
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	var trees []Tree
+	for i := 0; i < maxBinarySymbols+10; i += 1000 {
+		elems := make([]Tree, 0, 1000)
+		for j := i; j < i+1000; j++ {
+			elems = append(elems, Sym(fmt.Sprint("s", j)))
+		}
+		trees = append(trees, Lst(elems...))
+	}
+	trees = append(trees, Sym("s0"), Sym("s1"))
+	for _, tree := range trees {
+		err := enc.Encode(tree)
+		assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+	}
+
+	dec := NewBinaryDecoder(&buf)
+	for i, tree := range trees {
+		got, err := dec.Decode()
+		assert(t.Fatalf, err == nil && Equal(got, tree), "tree %d: expected %v, got %v, %v", i, tree, got, err)
+	}
+}
+
+func TestBinaryStream(t *testing.T) {
+	text := `(define (square x) (* x x)) 1.5 "s" (square 3) |a b|`
+	want := readAll(t, NewDecoder(strings.NewReader(text)))
+
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	for _, tree := range want {
+		err := enc.Encode(tree)
+		assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+	}
+
+	readers := map[string]io.Reader{
+		"buffered": bytes.NewReader(buf.Bytes()),
+		"oneByte":  iotest.OneByteReader(bytes.NewReader(buf.Bytes())),
+	}
+	for name, src := range readers {
+		dec := NewBinaryDecoder(src)
+		for i, tree := range want {
+			got, err := dec.Decode()
+			assert(t.Errorf, err == nil && Equal(got, tree), "%s: tree %d: expected %v, got %v, %v", name, i, tree, got, err)
+		}
+		_, err := dec.Decode()
+		assert(t.Errorf, err == io.EOF, "%s: expected io.EOF at the end, got %v", name, err)
+	}
+
+	_, err := NewBinaryDecoder(strings.NewReader("")).Decode()
+	assert(t.Errorf, err == io.EOF, "expected io.EOF for an empty stream, got %v", err)
+}
+
+func readAll(t *testing.T, dec *Decoder) []Tree {
+	var trees []Tree
+	for dec.More() {
+		tree, err := dec.Decode()
+		assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+		trees = append(trees, tree)
+	}
+	return trees
+}
+
+func TestBinaryErrors(t *testing.T) {
+	valid, _ := Lst(Sym("a"), Sym("a"), Num(1)).MarshalBinary()
+	header := binaryMagic + "\x01"
+	frame := func(body string) string { return header + string(rune(len(body))) + body }
+
+	cases := map[string]string{
+		"noHeader":          "(a)",
+		"badVersion":        binaryMagic + "\x02",
+		"unknownTag":        frame("\x7f"),
+		"symbolRefTooFar":   frame("\x08\x00"),
+		"zeroDenominator":   frame("\x04\x02\x01\x00"),
+		"leftoverInFrame":   frame("\x01\x02\x00"),
+		"emptyImproperList": frame("\x0a\x00\x01\x02"),
+		"listTail":          frame("\x0a\x01\x01\x02\x09\x00"),
+		"truncatedFrame":    string(valid[:len(valid)-1]),
+		"truncatedInFrame":  frame("\x05\x05ab"),
+		"leftoverData":      string(valid) + "x",
+	}
+	for name, data := range cases {
+		var tree Tree
+		err := tree.UnmarshalBinary([]byte(data))
+		assert(t.Errorf, err != nil, "%s: expected an error, got %v", name, tree)
+	}
+
+	dec := NewBinaryDecoder(strings.NewReader(string(valid[:len(valid)-1])))
+	_, err := dec.Decode()
+	assert(t.Errorf, errors.Is(err, io.ErrUnexpectedEOF), "expected an unexpected EOF, got %v", err)
+	_, again := dec.Decode()
+	assert(t.Errorf, again == err, "expected the error to stick, got %v", again)
+}
+
+// benchmarkTrees returns Trees like those of a typical program.
+func benchmarkTrees() []Tree {
+	trees := make([]Tree, 1000)
+	for i := range trees {
+		trees[i] = Lst(
+			Sym("define"),
+			Lst(Sym(fmt.Sprint("function-", i)), Sym("x"), Sym("y")),
+			Lst(Sym("let"), Lst(Lst(Sym("z"), Lst(Sym("+"), Sym("x"), Num(i)))),
+				Lst(Sym("if"), Lst(Sym("<"), Sym("z"), Float(2.5)),
+					Str("a string"),
+					Lst(Sym("*"), Sym("z"), Sym("y"), Rat(big.NewRat(1, 3))))))
+	}
+	return trees
+}
+
+func BenchmarkWriteSexpr(b *testing.B) {
+	trees := benchmarkTrees()
+	var buf bytes.Buffer
+	for b.Loop() {
+		buf.Reset()
+		for _, tree := range trees {
+			WriteSexpr(&buf, tree)
+			buf.WriteByte('\n')
+		}
+	}
+	b.SetBytes(int64(buf.Len()))
+}
+
+func BenchmarkBinaryEncoder(b *testing.B) {
+	trees := benchmarkTrees()
+	var buf bytes.Buffer
+	for b.Loop() {
+		buf.Reset()
+		enc := NewBinaryEncoder(&buf)
+		for _, tree := range trees {
+			enc.Encode(tree)
+		}
+	}
+	b.SetBytes(int64(buf.Len()))
+}
+
+func BenchmarkReadSexpr(b *testing.B) {
+	var buf bytes.Buffer
+	for _, tree := range benchmarkTrees() {
+		WriteSexpr(&buf, tree)
+		buf.WriteByte('\n')
+	}
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		src := bytes.NewReader(data)
+		for {
+			if _, err := ReadSexpr(src); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func BenchmarkBinaryDecoder(b *testing.B) {
+	var buf bytes.Buffer
+	enc := NewBinaryEncoder(&buf)
+	for _, tree := range benchmarkTrees() {
+		enc.Encode(tree)
+	}
+	data := buf.Bytes()
+	b.SetBytes(int64(len(data)))
+	for b.Loop() {
+		dec := NewBinaryDecoder(bytes.NewReader(data))
+		for {
+			if _, err := dec.Decode(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func TestBinaryDepthLimit(t *testing.T) {
+	nested := func(depth int) []byte {
+		body := strings.Repeat("\x09\x01", depth) + "\x00"
+		frame := binary.AppendUvarint([]byte(binaryMagic+"\x01"), uint64(len(body)))
+		return append(frame, body...)
+	}
+	var tree Tree
+	err := tree.UnmarshalBinary(nested(maxBinaryDepth))
+	assert(t.Errorf, err == nil, "unexpected error at the depth limit: %v", err)
+
+	for _, depth := range []int{maxBinaryDepth + 1, 5 << 20} {
+		err := tree.UnmarshalBinary(nested(depth))
+		assert(t.Errorf, err != nil, "expected an error for lists nested %d levels deep", depth)
+	}
+}
+
+// failingWriter fails the writes it is told to.
+type failingWriter struct {
+	bytes.Buffer
+	fail bool
+}
+
+func (fw *failingWriter) Write(p []byte) (int, error) {
+	if fw.fail {
+		return 0, errors.New("write failed")
+	}
+	return fw.Buffer.Write(p)
+}
+
+func TestBinaryEncoderErrorsStick(t *testing.T) {
+	var dst failingWriter
+	enc := NewBinaryEncoder(&dst)
+	enc.Encode(Sym("a"))
+
+	dst.fail = true
+	err := enc.Encode(Lst(Sym("b"), Sym("a")))
+	assert(t.Fatalf, err != nil, "expected the write to fail")
+
+	dst.fail = false
+	again := enc.Encode(Sym("b"))
+	assert(t.Errorf, again == err, "expected the error to stick, got %v", again)
+
+	dec := NewBinaryDecoder(&dst.Buffer)
+	tree, err := dec.Decode()
+	assert(t.Errorf, err == nil && Equal(tree, Sym("a")), "expected a, got %v, %v", tree, err)
+	_, err = dec.Decode()
+	assert(t.Errorf, err == io.EOF, "expected nothing after the failed write, got %v", err)
+}
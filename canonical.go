@@ -0,0 +1,388 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+)
+
+// Canonical s-expressions are the binary form of s-expressions described by Rivest.
+// There's exactly one way to write each of them, so they are good for hashing and signing.
+//
+// An atom is written as its length in bytes, a colon and the bytes themselves, as in 3:foo.
+// A list is written as its elements in parentheses, with nothing in between, as in (3:foo4:barz).
+// An atom can have a display hint before it, which is another atom in square brackets, as in [6:string]2:hi.
+//
+// Trees are written in canonical form as follows:
+//
+//   - a symbol is a plain atom
+//   - a string is an atom with the hint string
+//   - an integer of any size is an atom with the hint int and the digits as its bytes
+//   - a float is an atom with the hint float and the same text as WriteSexpr writes, except that negative zero is written as zero
+//   - a rational is an atom with the hint rat and a text like 1/3
+//   - a list is a list
+//   - an improper list has an empty atom with the hint . between the elements and the tail, as in (1:a1:b[1:.]0:1:c)
+//
+// So Equal Trees are always written as the same bytes.
+// The transport form is the canonical form encoded in base64 and put in braces, as in {KDM6Zm9vNDpiYXJ6KQ==}.
+
+// Display hints for the atoms that are not symbols.
+const (
+	stringHint = "string"
+	intHint    = "int"
+	floatHint  = "float"
+	ratHint    = "rat"
+	dotHint    = "."
+)
+
+// canonicalDot separates the elements of an improper list from its tail.
+const canonicalDot = "[1:.]0:"
+
+// AppendCanonical appends the canonical form of t to dst.
+// It fails for invalid Trees, returning dst with whatever was appended before the failure.
+func AppendCanonical(dst []byte, t Tree) ([]byte, error) {
+	if t.tag != symTreeList {
+		hint, text, ok := canonicalAtom(t)
+		if !ok {
+			return dst, errors.New("an invalid Tree has no canonical form")
+		}
+		if hint != "" {
+			dst = append(appendCanonicalAtom(append(dst, '['), hint), ']')
+		}
+		return appendCanonicalAtom(dst, text), nil
+	}
+	dst = append(dst, '(')
+	for i, elem := range t.list.elemsAndTail() {
+		var err error
+		if i == t.list.Len() {
+			dst = append(dst, canonicalDot...)
+		}
+		if dst, err = AppendCanonical(dst, elem); err != nil {
+			return dst, err
+		}
+	}
+	return append(dst, ')'), nil
+}
+
+// WriteCanonical writes the canonical form of t into dst.
+// Nothing is written for invalid Trees or Trees containing them.
+func WriteCanonical(dst io.Writer, t Tree) (n int, err error) {
+	data, err := AppendCanonical(nil, t)
+	if err != nil {
+		return 0, err
+	}
+	return dst.Write(data)
+}
+
+// WriteCanonicalTransport writes the transport form of t into dst.
+// Nothing is written for invalid Trees or Trees containing them.
+func WriteCanonicalTransport(dst io.Writer, t Tree) (n int, err error) {
+	data, err := AppendCanonical(nil, t)
+	if err != nil {
+		return 0, err
+	}
+	transport := make([]byte, 0, base64.StdEncoding.EncodedLen(len(data))+2)
+	transport = append(base64.StdEncoding.AppendEncode(append(transport, '{'), data), '}')
+	return dst.Write(transport)
+}
+
+// canonicalAtom returns the hint and the text of an atom in canonical form.
+// It fails for invalid Trees.
+func canonicalAtom(t Tree) (hint, text string, ok bool) {
+	switch t.tag {
+	case symTreeSymbol:
+		return "", t.symbol, true
+	case symTreeString:
+		return stringHint, t.symbol, true
+	case symTreeNumber:
+		return intHint, strconv.Itoa(t.number), true
+	case symTreeBigInt:
+		return intHint, t.bigInt.String(), true
+	case symTreeFloat:
+		f := t.float
+		if f == 0 {
+			// Negative zero is Equal to zero.
+			f = 0
+		}
+		return floatHint, formatFloat(f), true
+	case symTreeRat:
+		return ratHint, t.rat.String(), true
+	}
+	return "", "", false
+}
+
+func appendCanonicalAtom(dst []byte, text string) []byte {
+	dst = strconv.AppendInt(dst, int64(len(text)), 10)
+	return append(append(dst, ':'), text...)
+}
+
+// ReadCanonical reads a Tree written in canonical or transport form.
+// It reads nothing past the end of the Tree.
+//
+// Only what WriteCanonical could have written is accepted.
+// Atoms without hints are read as symbols, and the other hints need to be the ones WriteCanonical uses,
+// with the atom text exactly as WriteCanonical writes it.
+//
+// When src is empty, ReadCanonical returns io.EOF.
+// When it ends in the middle of a Tree, the error wraps io.ErrUnexpectedEOF.
+// Lists nested more than 10000 levels deep are an error.
+func ReadCanonical(src io.ByteReader) (Tree, error) {
+	r := canonicalReader{src: src}
+	b, err := src.ReadByte()
+	if err != nil {
+		return Tree{}, err
+	}
+	r.offset++
+	if b == '{' {
+		return r.readTransport()
+	}
+	return r.readAtomOrList(b)
+}
+
+// A canonicalReader reads canonical s-expressions, keeping track of the offset for error messages.
+type canonicalReader struct {
+	src    io.ByteReader
+	offset int
+	depth  int
+}
+
+// maxCanonicalDepth limits how deeply lists can be nested in the input of ReadCanonical.
+// It keeps malicious input from overflowing the stack.
+const maxCanonicalDepth = 10000
+
+func (r *canonicalReader) readByte() (byte, error) {
+	b, err := r.src.ReadByte()
+	if err == io.EOF {
+		return 0, r.errorf("%w", io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return 0, err
+	}
+	r.offset++
+	return b, nil
+}
+
+// errorf makes an error saying what's wrong with the byte just read.
+func (r *canonicalReader) errorf(format string, args ...any) error {
+	return fmt.Errorf("invalid canonical s-expression at byte %d: %w", r.offset, fmt.Errorf(format, args...))
+}
+
+func (r *canonicalReader) readTransport() (Tree, error) {
+	var encoded []byte
+	for {
+		b, err := r.readByte()
+		if err != nil {
+			return Tree{}, err
+		}
+		if b == '}' {
+			break
+		}
+		if !bytes.ContainsRune([]byte(" \t\r\n"), rune(b)) {
+			encoded = append(encoded, b)
+		}
+	}
+	data, err := base64.StdEncoding.AppendDecode(nil, encoded)
+	if err != nil {
+		return Tree{}, r.errorf("the transport form is not valid base64: %w", err)
+	}
+	src := bytes.NewReader(data)
+	inner := canonicalReader{src: src}
+	b, err := inner.readByte()
+	if err != nil {
+		return Tree{}, r.errorf("the transport form is empty")
+	}
+	t, err := inner.readAtomOrList(b)
+	if err != nil {
+		return Tree{}, fmt.Errorf("in the transport form at byte %d: %w", r.offset, err)
+	}
+	if src.Len() > 0 {
+		return Tree{}, r.errorf("the transport form holds more than one s-expression")
+	}
+	return t, nil
+}
+
+// readAtomOrList reads a Tree starting with the byte b, which has already been read.
+// It fails on a dot.
+func (r *canonicalReader) readAtomOrList(b byte) (Tree, error) {
+	t, dot, err := r.readTree(b)
+	if err == nil && dot {
+		return Tree{}, r.errorf("a dot can only be between the elements and the tail of a list")
+	}
+	return t, err
+}
+
+// readTree reads a Tree or a dot starting with the byte b, which has already been read.
+func (r *canonicalReader) readTree(b byte) (t Tree, dot bool, err error) {
+	switch {
+	case b == '(':
+		t, err = r.readList()
+		return t, false, err
+	case b == '[':
+		return r.readHinted()
+	case isDigit(b):
+		name, err := r.readAtom(b)
+		return Sym(name), false, err
+	}
+	return Tree{}, false, r.errorf("unexpected %q", b)
+}
+
+func isDigit(b byte) bool { return '0' <= b && b <= '9' }
+
+func (r *canonicalReader) readList() (Tree, error) {
+	if r.depth == maxCanonicalDepth {
+		return Tree{}, r.errorf("lists are nested more than %d levels deep", maxCanonicalDepth)
+	}
+	r.depth++
+	defer func() { r.depth-- }()
+
+	var elems []Tree
+	for {
+		b, err := r.readByte()
+		if err != nil {
+			return Tree{}, err
+		}
+		if b == ')' {
+			return Lst(elems...), nil
+		}
+		elem, dot, err := r.readTree(b)
+		if err != nil {
+			return Tree{}, err
+		}
+		if dot {
+			return r.readTail(elems)
+		}
+		elems = append(elems, elem)
+	}
+}
+
+// readTail reads the tail of an improper list, right after the dot, and the end of the list.
+func (r *canonicalReader) readTail(elems []Tree) (Tree, error) {
+	if len(elems) == 0 {
+		return Tree{}, r.errorf("a dot needs to follow the elements of a list")
+	}
+	b, err := r.readByte()
+	if err != nil {
+		return Tree{}, err
+	}
+	tail, err := r.readAtomOrList(b)
+	if err != nil {
+		return Tree{}, err
+	}
+	if tail.tag == symTreeList {
+		return Tree{}, r.errorf("the tail of a list must be an atom")
+	}
+	if b, err = r.readByte(); err != nil {
+		return Tree{}, err
+	}
+	if b != ')' {
+		return Tree{}, r.errorf("expected the list to end after its tail, found %q", b)
+	}
+	return DottedLst(elems, tail), nil
+}
+
+// readHinted reads an atom with a display hint, right after the opening bracket.
+// It might read a dot instead.
+func (r *canonicalReader) readHinted() (t Tree, dot bool, err error) {
+	hint, err := r.readAtomAfter("a display hint")
+	if err != nil {
+		return Tree{}, false, err
+	}
+	b, err := r.readByte()
+	if err != nil {
+		return Tree{}, false, err
+	}
+	if b != ']' {
+		return Tree{}, false, r.errorf("expected the display hint to end, found %q", b)
+	}
+	text, err := r.readAtomAfter("an atom after the display hint")
+	if err != nil {
+		return Tree{}, false, err
+	}
+
+	if hint == dotHint && text == "" {
+		return Tree{}, true, nil
+	}
+	t = hintedAtom(hint, text)
+	if gotHint, gotText, ok := canonicalAtom(t); !ok || gotHint != hint || gotText != text {
+		return Tree{}, false, r.errorf("%q with the hint %q is not in canonical form", text, hint)
+	}
+	return t, false, nil
+}
+
+// readAtomAfter reads an atom, which is what's expected.
+func (r *canonicalReader) readAtomAfter(expected string) (string, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return "", err
+	}
+	if !isDigit(b) {
+		return "", r.errorf("expected %s, found %q", expected, b)
+	}
+	return r.readAtom(b)
+}
+
+// hintedAtom makes a Tree out of an atom with a hint.
+// The result is invalid when the text doesn't make sense for the hint.
+func hintedAtom(hint, text string) Tree {
+	switch hint {
+	case stringHint:
+		return Str(text)
+	case intHint:
+		if n, ok := new(big.Int).SetString(text, 10); ok {
+			return BigInt(n)
+		}
+	case floatHint:
+		if f, ok := parseFloat(text); ok {
+			return Float(f)
+		}
+	case ratHint:
+		if r, ok := new(big.Rat).SetString(text); ok {
+			return Rat(r)
+		}
+	}
+	return Tree{}
+}
+
+// readAtom reads the length and the bytes of an atom, when the first digit of the length has been read.
+func (r *canonicalReader) readAtom(b byte) (string, error) {
+	length := int(b - '0')
+	for {
+		d, err := r.readByte()
+		if err != nil {
+			return "", err
+		}
+		if d == ':' {
+			break
+		}
+		if !isDigit(d) {
+			return "", r.errorf("expected a digit or a colon, found %q", d)
+		}
+		if length == 0 {
+			return "", r.errorf("the length of an atom can't have leading zeros")
+		}
+		if length > (maxAtomLength-int(d-'0'))/10 {
+			return "", r.errorf("an atom can't be longer than %d bytes", maxAtomLength)
+		}
+		length = length*10 + int(d-'0')
+	}
+	text := make([]byte, 0, min(length, 4096))
+	for range length {
+		d, err := r.readByte()
+		if err != nil {
+			return "", err
+		}
+		text = append(text, d)
+	}
+	return string(text), nil
+}
+
+// maxAtomLength keeps the length of an atom from overflowing an int.
+const maxAtomLength = 1<<31 - 1
@@ -0,0 +1,143 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	cases := map[string]struct {
+		tree Tree
+		text string
+	}{
+		"symbol":       {Sym("foo"), "3:foo"},
+		"emptySymbol":  {Sym(""), "0:"},
+		"string":       {Str("a b"), "[6:string]3:a b"},
+		"number":       {Num(-42), "[3:int]3:-42"},
+		"bigInt":       {BigInt(hugeInt()), "[3:int]30:" + hugeInt().String()},
+		"float":        {Float(2.5), "[5:float]3:2.5"},
+		"negativeZero": {Float(math.Copysign(0, -1)), "[5:float]3:0.0"},
+		"nan":          {Float(math.NaN()), "[5:float]6:+nan.0"},
+		"rat":          {Rat(big.NewRat(2, 6)), "[3:rat]3:1/3"},
+		"list":         {Lst(Sym("foo"), Sym("barz")), "(3:foo4:barz)"},
+		"emptyList":    {Lst(), "()"},
+		"nested":       {Lst(Lst(), Lst(Str(""))), "(()([6:string]0:))"},
+		"improperList": {DottedLst([]Tree{Sym("a"), Sym("b")}, Num(1)), "(1:a1:b[1:.]0:[3:int]1:1)"},
+		"binary":       {Sym("\x00(\xff"), "3:\x00(\xff"},
+	}
+	for name, c := range cases {
+		var buf bytes.Buffer
+		n, err := WriteCanonical(&buf, c.tree)
+		assert(t.Errorf, err == nil && buf.String() == c.text, "%s: expected %q, got %q, %v", name, c.text, buf.String(), err)
+		assert(t.Errorf, n == buf.Len(), "%s: reported %d bytes written, not %d", name, n, buf.Len())
+
+		back, err := ReadCanonical(strings.NewReader(c.text))
+		assert(t.Errorf, err == nil && Equal(back, c.tree), "%s: expected %v back, got %v, %v", name, c.tree, back, err)
+
+		buf.Reset()
+		WriteCanonicalTransport(&buf, c.tree)
+		back, err = ReadCanonical(&buf)
+		assert(t.Errorf, err == nil && Equal(back, c.tree), "%s: expected %v back from the transport form, got %v, %v", name, c.tree, back, err)
+	}
+}
+
+func TestCanonicalEqualTreesGiveEqualBytes(t *testing.T) {
+	pairs := map[string][2]Tree{
+		"zeros":    {Float(0), Float(math.Copysign(0, -1))},
+		"nans":     {Float(math.NaN()), Float(math.Float64frombits(0x7ff8000000000001))},
+		"rats":     {Rat(big.NewRat(1, 2)), Rat(big.NewRat(-3, -6))},
+		"interned": {NewFactory().Lst(Sym("a"), Num(1)), Lst(Sym("a"), Num(1))},
+		"spliced":  {DottedLst([]Tree{Sym("a")}, Lst(Sym("b"))), Lst(Sym("a"), Sym("b"))},
+	}
+	for name, pair := range pairs {
+		a, errA := AppendCanonical(nil, pair[0])
+		b, errB := AppendCanonical(nil, pair[1])
+		assert(t.Errorf, errA == nil && errB == nil && bytes.Equal(a, b), "%s: expected equal bytes, got %q and %q", name, a, b)
+	}
+
+	spanned, _ := NewDecoder(strings.NewReader(`(a "b" 1.5)`)).Decode()
+	plain := Lst(Sym("a"), Str("b"), Float(1.5))
+	a, _ := AppendCanonical(nil, spanned)
+	b, _ := AppendCanonical(nil, plain)
+	assert(t.Errorf, bytes.Equal(a, b), "spans changed the bytes: %q and %q", a, b)
+}
+
+func TestReadCanonicalTransport(t *testing.T) {
+	tree, err := ReadCanonical(strings.NewReader("{KDM6Zm9v\n NDpiYXJ6KQ==}"))
+	want := Lst(Sym("foo"), Sym("barz"))
+	assert(t.Errorf, err == nil && Equal(tree, want), "expected %v, got %v, %v", want, tree, err)
+}
+
+func TestReadCanonicalStopsAtTheEnd(t *testing.T) {
+	src := strings.NewReader("(1:a)1:b")
+	first, err := ReadCanonical(src)
+	assert(t.Errorf, err == nil && Equal(first, Lst(Sym("a"))), "expected (a), got %v, %v", first, err)
+	second, err := ReadCanonical(src)
+	assert(t.Errorf, err == nil && Equal(second, Sym("b")), "expected b, got %v, %v", second, err)
+	_, err = ReadCanonical(src)
+	assert(t.Errorf, err == io.EOF, "expected io.EOF, got %v", err)
+}
+
+func TestReadCanonicalErrors(t *testing.T) {
+	cases := map[string]string{
+		"whitespace":       "(1:a 1:b)",
+		"leadingZero":      "01:a",
+		"unclosedHint":     "[3:int1:1",
+		"unknownHint":      "[3:foo1:a",
+		"nonCanonicalInt":  "[3:int]2:01",
+		"plusInt":          "[3:int]2:+1",
+		"negativeZeroInt":  "[3:int]2:-0",
+		"nonCanonicalRat":  "[3:rat]3:2/4",
+		"ratWithoutDenom":  "[3:rat]1:1",
+		"negativeZero":     "[5:float]4:-0.0",
+		"floatNotShortest": "[5:float]4:2.50",
+		"notAFloat":        "[5:float]1:x",
+		"topLevelDot":      "[1:.]0:",
+		"leadingDot":       "([1:.]0:1:a)",
+		"listTail":         "(1:a[1:.]0:(1:b))",
+		"twoTails":         "(1:a[1:.]0:1:b1:c)",
+		"strayClose":       ")",
+		"badBase64":        "{!!}",
+		"emptyTransport":   "{}",
+		"twoInTransport":   "{MTphMTpi}",
+		"hugeLength":       "99999999999999999999:a",
+	}
+	for name, text := range cases {
+		tree, err := ReadCanonical(strings.NewReader(text))
+		assert(t.Errorf, err != nil, "%s: expected an error, got %v", name, tree)
+	}
+
+	for _, text := range []string{"(1:a", "3:fo", "[3:int]", "(1:a[1:.]0:1:b", "{KDM6"} {
+		_, err := ReadCanonical(strings.NewReader(text))
+		assert(t.Errorf, errors.Is(err, io.ErrUnexpectedEOF), "%q: expected an unexpected EOF, got %v", text, err)
+	}
+}
+
+func TestReadCanonicalDepthLimit(t *testing.T) {
+	nested := func(depth int) *strings.Reader {
+		return strings.NewReader(strings.Repeat("(", depth) + strings.Repeat(")", depth))
+	}
+	_, err := ReadCanonical(nested(maxCanonicalDepth))
+	assert(t.Errorf, err == nil, "unexpected error at the depth limit: %v", err)
+
+	for _, depth := range []int{maxCanonicalDepth + 1, 3 << 20} {
+		_, err := ReadCanonical(nested(depth))
+		assert(t.Errorf, err != nil, "expected an error for lists nested %d levels deep", depth)
+	}
+}
+
+func TestWriteCanonicalInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	_, err := WriteCanonical(&buf, Lst(Sym("a"), Tree{}))
+	assert(t.Errorf, err != nil, "expected an error")
+	assert(t.Errorf, buf.Len() == 0, "expected nothing to be written, got %q", buf.String())
+}
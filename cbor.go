@@ -0,0 +1,616 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"slices"
+	"unicode/utf8"
+)
+
+// Trees are written in CBOR, as defined by RFC 8949, as follows:
+//
+//   - a symbol is a text string with tag 39, the identifier tag
+//   - an integer is an integer, or a bignum with tag 2 or 3 when it doesn't fit in 64 bits
+//   - a float is a float, as short as it can be without losing precision
+//   - a rational is a two element array of the numerator and the denominator with tag 30, the rational number tag
+//   - a string is a text string
+//   - a list is an array of its elements
+//   - an improper list is an array of its elements, the undefined value and the tail
+//
+// Symbols and strings that are not valid UTF-8 are byte strings instead of text strings.
+// Invalid Trees can't be written.
+//
+// Besides what's written, the decoder accepts
+//
+//   - byte strings as strings
+//   - the values true, false and null as the symbols true, false and nil
+//   - maps as lists of (key value) lists, ordered the way Compare orders them
+//   - indefinite length strings, arrays and maps
+//   - anything with tag 55799, the self-described CBOR tag
+//
+// These are the same conventions Marshal and Unmarshal use for nil, bools and maps.
+
+// Major types.
+const (
+	cborUint = iota << 5
+	cborNegInt
+	cborBytes
+	cborText
+	cborArray
+	cborMap
+	cborTag
+	cborSimple
+)
+
+// Tag numbers.
+const (
+	cborPosBignumTag  = 2
+	cborNegBignumTag  = 3
+	cborRationalTag   = 30
+	cborIdentifierTag = 39
+	cborSelfDescribed = 55799
+)
+
+// Additional information that isn't a number.
+const (
+	cborFalse      = 20
+	cborTrue       = 21
+	cborNull       = 22
+	cborUndefined  = 23
+	cborFloat16    = 25
+	cborFloat32    = 26
+	cborFloat64    = 27
+	cborIndefinite = 31
+	cborBreak      = cborSimple | cborIndefinite
+)
+
+// MarshalCBOR encodes a Tree in CBOR.
+// Spans are not kept.
+func (tree Tree) MarshalCBOR() ([]byte, error) {
+	return appendCBOR(nil, tree)
+}
+
+func appendCBOR(dst []byte, t Tree) ([]byte, error) {
+	switch t.tag {
+	case symTreeInvalid:
+		return dst, errors.New("an invalid Tree can't be written in CBOR")
+	case symTreeSymbol:
+		return appendCBORText(appendCBORHead(dst, cborTag, cborIdentifierTag), t.symbol), nil
+	case symTreeNumber:
+		return appendCBORInt(dst, big.NewInt(int64(t.number))), nil
+	case symTreeBigInt:
+		return appendCBORInt(dst, t.bigInt), nil
+	case symTreeFloat:
+		return appendCBORFloat(dst, t.float), nil
+	case symTreeRat:
+		dst = appendCBORHead(appendCBORHead(dst, cborTag, cborRationalTag), cborArray, 2)
+		return appendCBORInt(appendCBORInt(dst, t.rat.Num()), t.rat.Denom()), nil
+	case symTreeString:
+		return appendCBORText(dst, t.symbol), nil
+	}
+	tail, improper := t.list.Tail()
+	n := t.list.Len()
+	if improper {
+		n += 2
+	}
+	dst = appendCBORHead(dst, cborArray, uint64(n))
+	var err error
+	for _, elem := range t.list.All() {
+		if dst, err = appendCBOR(dst, elem); err != nil {
+			return dst, err
+		}
+	}
+	if improper {
+		return appendCBOR(append(dst, cborSimple|cborUndefined), tail)
+	}
+	return dst, nil
+}
+
+// appendCBORHead appends the first byte of an item of the major type, followed by the argument.
+func appendCBORHead(dst []byte, major byte, arg uint64) []byte {
+	switch {
+	case arg < 24:
+		return append(dst, major|byte(arg))
+	case arg <= math.MaxUint8:
+		return append(dst, major|24, byte(arg))
+	case arg <= math.MaxUint16:
+		return append(dst, major|25, byte(arg>>8), byte(arg))
+	case arg <= math.MaxUint32:
+		return append(dst, major|26, byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+	}
+	return append(dst, major|27, byte(arg>>56), byte(arg>>48), byte(arg>>40), byte(arg>>32),
+		byte(arg>>24), byte(arg>>16), byte(arg>>8), byte(arg))
+}
+
+// appendCBORText appends text as a text string or, if it's not valid UTF-8, as a byte string.
+func appendCBORText(dst []byte, text string) []byte {
+	major := byte(cborText)
+	if !utf8.ValidString(text) {
+		major = cborBytes
+	}
+	return append(appendCBORHead(dst, major, uint64(len(text))), text...)
+}
+
+// appendCBORInt appends an integer, using a bignum only when it has to.
+func appendCBORInt(dst []byte, n *big.Int) []byte {
+	if n.Sign() >= 0 {
+		if n.IsUint64() {
+			return appendCBORHead(dst, cborUint, n.Uint64())
+		}
+		abs := n.Bytes()
+		return append(appendCBORHead(appendCBORHead(dst, cborTag, cborPosBignumTag), cborBytes, uint64(len(abs))), abs...)
+	}
+	// A negative integer n is encoded as -1 - n.
+	m := new(big.Int).Not(n)
+	if m.IsUint64() {
+		return appendCBORHead(dst, cborNegInt, m.Uint64())
+	}
+	abs := m.Bytes()
+	return append(appendCBORHead(appendCBORHead(dst, cborTag, cborNegBignumTag), cborBytes, uint64(len(abs))), abs...)
+}
+
+// appendCBORFloat appends a float in the shortest of the three sizes that can hold it exactly.
+func appendCBORFloat(dst []byte, f float64) []byte {
+	if math.IsNaN(f) {
+		return append(dst, cborSimple|cborFloat16, 0x7e, 0x00)
+	}
+	if h, ok := toFloat16(f); ok {
+		return append(dst, cborSimple|cborFloat16, byte(h>>8), byte(h))
+	}
+	if float64(float32(f)) == f {
+		bits := math.Float32bits(float32(f))
+		return append(dst, cborSimple|cborFloat32, byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+	}
+	bits := math.Float64bits(f)
+	return append(dst, cborSimple|cborFloat64, byte(bits>>56), byte(bits>>48), byte(bits>>40), byte(bits>>32),
+		byte(bits>>24), byte(bits>>16), byte(bits>>8), byte(bits))
+}
+
+// toFloat16 finds the half-precision bits of a float, if it can be held exactly in half precision.
+func toFloat16(f float64) (uint16, bool) {
+	var h uint16
+	if math.Signbit(f) {
+		h = 1 << 15
+	}
+	a := math.Abs(f)
+	switch {
+	case a == 0:
+	case math.IsInf(a, 1):
+		h |= 0x1f << 10
+	case a < 0x1p-14:
+		// Subnormal numbers are multiples of 2^-24.
+		m := a * 0x1p24
+		if m != math.Trunc(m) {
+			return 0, false
+		}
+		h |= uint16(m)
+	default:
+		frac, exp := math.Frexp(a)
+		m := frac * 0x1p11
+		if m != math.Trunc(m) || exp+14 > 30 {
+			return 0, false
+		}
+		h |= uint16(exp+14)<<10 | (uint16(m) - 0x400)
+	}
+	return h, true
+}
+
+// fromFloat16 converts half-precision bits to a float.
+func fromFloat16(h uint16) float64 {
+	exp, m := int(h>>10&0x1f), float64(h&0x3ff)
+	var f float64
+	switch exp {
+	case 0:
+		f = math.Ldexp(m, -24)
+	case 0x1f:
+		f = math.Inf(1)
+		if m != 0 {
+			f = math.NaN()
+		}
+	default:
+		f = math.Ldexp(m+0x400, exp-25)
+	}
+	if h>>15 == 1 {
+		f = -f
+	}
+	return f
+}
+
+// UnmarshalCBOR decodes a Tree from CBOR.
+// The data must hold exactly one item.
+func (tree *Tree) UnmarshalCBOR(data []byte) error {
+	src := bytes.NewReader(data)
+	t, err := NewCBORDecoder(src).Decode()
+	if err == io.EOF {
+		err = cborError(io.ErrUnexpectedEOF)
+	}
+	if err != nil {
+		return err
+	}
+	if src.Len() > 0 {
+		return cborError(errors.New("there's data left after the item"))
+	}
+	*tree = t
+	return nil
+}
+
+// A CBOREncoder writes a sequence of Trees to an output stream in CBOR, one item after another.
+type CBOREncoder struct {
+	dst io.Writer
+	buf []byte
+}
+
+// NewCBOREncoder creates a CBOREncoder that writes to dst.
+func NewCBOREncoder(dst io.Writer) *CBOREncoder {
+	return &CBOREncoder{dst: dst}
+}
+
+// Encode writes t to the output.
+// Each Tree is written with a single call to the Write method of the output.
+// Nothing is written for invalid Trees or Trees containing them.
+func (e *CBOREncoder) Encode(t Tree) error {
+	var err error
+	if e.buf, err = appendCBOR(e.buf[:0], t); err != nil {
+		return err
+	}
+	_, err = e.dst.Write(e.buf)
+	return err
+}
+
+// A CBORDecoder reads a sequence of Trees in CBOR from an input stream.
+type CBORDecoder struct {
+	src   byteReader
+	buf   bytes.Buffer
+	depth int
+	err   error
+}
+
+// maxCBORDepth limits how deeply items can be nested in the input of a CBORDecoder.
+// Without it, untrusted input could make decoding run out of stack.
+const maxCBORDepth = 10000
+
+// NewCBORDecoder creates a CBORDecoder that reads from src.
+// The CBORDecoder buffers src, unless it is an io.ByteReader already.
+// It then reads nothing past the end of the last Tree it decodes.
+func NewCBORDecoder(src io.Reader) *CBORDecoder {
+	br, ok := src.(byteReader)
+	if !ok {
+		br = bufio.NewReader(src)
+	}
+	return &CBORDecoder{src: br}
+}
+
+// Decode reads the next Tree from the input.
+//
+// When there are no Trees left, the error is io.EOF.
+// When the input ends in the middle of a Tree, the error wraps io.ErrUnexpectedEOF.
+// Items nested more than 10000 levels deep are an error as well, to keep untrusted input from exhausting the stack.
+// After an error, all further calls to Decode fail the same way.
+func (d *CBORDecoder) Decode() (Tree, error) {
+	if d.err != nil {
+		return Tree{}, d.err
+	}
+	item, err := d.head()
+	if err == io.EOF {
+		d.err = io.EOF
+		return Tree{}, io.EOF
+	}
+	var t Tree
+	if err == nil {
+		t, err = d.atomOrListAfter(item)
+	}
+	if err != nil {
+		d.err = cborError(orUnexpectedEOF(err))
+		return Tree{}, d.err
+	}
+	return t, nil
+}
+
+func cborError(err error) error {
+	return fmt.Errorf("invalid CBOR: %w", err)
+}
+
+// A cborItem is what the first byte of a CBOR item says.
+type cborItem struct {
+	major byte
+	info  byte
+	arg   uint64
+}
+
+func (item cborItem) isBreak() bool { return item.major|item.info == cborBreak }
+
+func (d *CBORDecoder) head() (cborItem, error) {
+	b, err := d.src.ReadByte()
+	if err != nil {
+		return cborItem{}, err
+	}
+	item := cborItem{major: b &^ 0x1f, info: b & 0x1f}
+	switch {
+	case item.info < 24:
+		item.arg = uint64(item.info)
+	case item.info <= 27:
+		for range 1 << (item.info - 24) {
+			b, err := d.src.ReadByte()
+			if err != nil {
+				return cborItem{}, orUnexpectedEOF(err)
+			}
+			item.arg = item.arg<<8 | uint64(b)
+		}
+	case item.info == cborIndefinite && item.major != cborUint && item.major != cborNegInt && item.major != cborTag:
+	default:
+		return cborItem{}, fmt.Errorf("reserved additional information %d", item.info)
+	}
+	return item, nil
+}
+
+// atomOrList reads a Tree, failing on the undefined value.
+func (d *CBORDecoder) atomOrList() (Tree, error) {
+	item, err := d.head()
+	if err != nil {
+		return Tree{}, err
+	}
+	return d.atomOrListAfter(item)
+}
+
+// atomOrListAfter is like atomOrList, when the first byte has been read already.
+func (d *CBORDecoder) atomOrListAfter(item cborItem) (Tree, error) {
+	t, undefined, err := d.tree(item)
+	if err == nil && undefined {
+		err = errors.New("the undefined value can only come right before the tail of an improper list")
+	}
+	return t, err
+}
+
+// tree reads a Tree, or the undefined value, when the first byte has been read already.
+func (d *CBORDecoder) tree(item cborItem) (t Tree, undefined bool, err error) {
+	if d.depth == maxCBORDepth {
+		return Tree{}, false, fmt.Errorf("items are nested more than %d levels deep", maxCBORDepth)
+	}
+	d.depth++
+	defer func() { d.depth-- }()
+
+	switch item.major {
+	case cborUint:
+		return BigInt(new(big.Int).SetUint64(item.arg)), false, nil
+	case cborNegInt:
+		return BigInt(new(big.Int).Not(new(big.Int).SetUint64(item.arg))), false, nil
+	case cborBytes, cborText:
+		text, err := d.text(item)
+		return Str(text), false, err
+	case cborArray:
+		t, err := d.array(item)
+		return t, false, err
+	case cborMap:
+		t, err := d.mapTree(item)
+		return t, false, err
+	case cborTag:
+		t, err := d.tagged(item.arg)
+		return t, false, err
+	}
+	switch item.info {
+	case cborFalse:
+		return Sym("false"), false, nil
+	case cborTrue:
+		return Sym("true"), false, nil
+	case cborNull:
+		return nilSymbol, false, nil
+	case cborUndefined:
+		return Tree{}, true, nil
+	case cborFloat16:
+		return Float(fromFloat16(uint16(item.arg))), false, nil
+	case cborFloat32:
+		return Float(float64(math.Float32frombits(uint32(item.arg)))), false, nil
+	case cborFloat64:
+		return Float(math.Float64frombits(item.arg)), false, nil
+	case cborIndefinite:
+		return Tree{}, false, errors.New("unexpected break")
+	}
+	return Tree{}, false, fmt.Errorf("unsupported simple value %d", item.arg)
+}
+
+// text reads a text or byte string, when its first byte has been read already.
+func (d *CBORDecoder) text(item cborItem) (string, error) {
+	if item.info != cborIndefinite {
+		return d.bytes(item.arg)
+	}
+	var chunks []byte
+	for {
+		chunk, err := d.head()
+		if err != nil {
+			return "", err
+		}
+		if chunk.isBreak() {
+			return string(chunks), nil
+		}
+		if chunk.major != item.major || chunk.info == cborIndefinite {
+			return "", errors.New("a chunk of an indefinite length string must be a definite length string of the same type")
+		}
+		text, err := d.bytes(chunk.arg)
+		if err != nil {
+			return "", err
+		}
+		chunks = append(chunks, text...)
+	}
+}
+
+func (d *CBORDecoder) bytes(n uint64) (string, error) {
+	if n > math.MaxInt64 {
+		return "", errors.New("a string is too long")
+	}
+	d.buf.Reset()
+	if _, err := io.CopyN(&d.buf, d.src, int64(n)); err != nil {
+		return "", err
+	}
+	return d.buf.String(), nil
+}
+
+// items reads the items of an array or a map, when its first byte has been read already.
+// It calls f on the first byte of each item, for as many items as there are.
+func (d *CBORDecoder) items(item cborItem, count uint64, f func(cborItem) error) error {
+	for i := uint64(0); item.info == cborIndefinite || i < count; i++ {
+		next, err := d.head()
+		if err != nil {
+			return err
+		}
+		if item.info == cborIndefinite && next.isBreak() {
+			return nil
+		}
+		if err := f(next); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *CBORDecoder) array(item cborItem) (Tree, error) {
+	var elems []Tree
+	dot := -1
+	err := d.items(item, item.arg, func(next cborItem) error {
+		elem, undefined, err := d.tree(next)
+		if undefined {
+			if dot >= 0 {
+				return errors.New("an array has more than one undefined value")
+			}
+			dot = len(elems)
+			return nil
+		}
+		elems = append(elems, elem)
+		return err
+	})
+	if err != nil {
+		return Tree{}, err
+	}
+	if dot < 0 {
+		return Lst(elems...), nil
+	}
+	if dot == 0 || dot != len(elems)-1 || elems[dot].tag == symTreeList {
+		return Tree{}, errors.New("an improper list needs elements, the undefined value and a tail that is not a list")
+	}
+	return DottedLst(elems[:dot], elems[dot]), nil
+}
+
+func (d *CBORDecoder) mapTree(item cborItem) (Tree, error) {
+	var entries []Tree
+	var key Tree
+	count := item.arg * 2
+	if item.arg > math.MaxUint64/2 {
+		return Tree{}, errors.New("a map is too long")
+	}
+	isKey := true
+	err := d.items(item, count, func(next cborItem) error {
+		t, undefined, err := d.tree(next)
+		if err == nil && undefined {
+			err = errors.New("the undefined value can't be in a map")
+		}
+		if err != nil {
+			return err
+		}
+		if isKey {
+			key = t
+		} else {
+			entries = append(entries, Lst(key, t))
+		}
+		isKey = !isKey
+		return nil
+	})
+	if err == nil && !isKey {
+		err = errors.New("a map has a key without a value")
+	}
+	if err != nil {
+		return Tree{}, err
+	}
+	slices.SortStableFunc(entries, Compare)
+	return Lst(entries...), nil
+}
+
+func (d *CBORDecoder) tagged(tag uint64) (Tree, error) {
+	switch tag {
+	case cborSelfDescribed:
+		return d.atomOrList()
+	case cborIdentifierTag:
+		item, err := d.head()
+		if err != nil {
+			return Tree{}, err
+		}
+		if item.major != cborText && item.major != cborBytes {
+			return Tree{}, errors.New("a symbol must be a string")
+		}
+		name, err := d.text(item)
+		return Sym(name), err
+	case cborPosBignumTag, cborNegBignumTag:
+		n, err := d.bignum()
+		if err != nil {
+			return Tree{}, err
+		}
+		if tag == cborNegBignumTag {
+			n.Not(n)
+		}
+		return BigInt(n), nil
+	case cborRationalTag:
+		item, err := d.head()
+		if err != nil {
+			return Tree{}, err
+		}
+		if item.major != cborArray || item.arg != 2 {
+			return Tree{}, errors.New("a rational must be an array of two integers")
+		}
+		num, err := d.integer()
+		if err != nil {
+			return Tree{}, err
+		}
+		denom, err := d.integer()
+		if err != nil {
+			return Tree{}, err
+		}
+		if denom.Sign() <= 0 {
+			return Tree{}, errors.New("the denominator of a rational must be positive")
+		}
+		return Rat(new(big.Rat).SetFrac(num, denom)), nil
+	}
+	return Tree{}, fmt.Errorf("unsupported tag %d", tag)
+}
+
+// bignum reads the byte string of a bignum.
+func (d *CBORDecoder) bignum() (*big.Int, error) {
+	item, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if item.major != cborBytes {
+		return nil, errors.New("a bignum must be a byte string")
+	}
+	abs, err := d.text(item)
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes([]byte(abs)), nil
+}
+
+// integer reads an integer or a bignum.
+func (d *CBORDecoder) integer() (*big.Int, error) {
+	item, err := d.head()
+	if err != nil {
+		return nil, err
+	}
+	if item.major != cborUint && item.major != cborNegInt && item.major != cborTag {
+		return nil, errors.New("expected an integer")
+	}
+	t, _, err := d.tree(item)
+	if err != nil {
+		return nil, err
+	}
+	n, ok := integerValue(t)
+	if !ok {
+		return nil, errors.New("expected an integer")
+	}
+	return n, nil
+}
@@ -0,0 +1,214 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCBOR(t *testing.T) {
+	bigPositive, _ := new(big.Int).SetString("18446744073709551616", 10)
+	bigNegative, _ := new(big.Int).SetString("-18446744073709551617", 10)
+	largestNegative, _ := new(big.Int).SetString("-18446744073709551616", 10)
+	// Most of the cases come from appendix A of RFC 8949.
+	cases := map[string]struct {
+		tree Tree
+		hex  string
+	}{
+		"zero":            {Num(0), "00"},
+		"23":              {Num(23), "17"},
+		"24":              {Num(24), "1818"},
+		"1000":            {Num(1000), "1903e8"},
+		"1000000":         {Num(1000000), "1a000f4240"},
+		"1e12":            {Num(1000000000000), "1b000000e8d4a51000"},
+		"largestUint":     {BigInt(new(big.Int).SetUint64(math.MaxUint64)), "1bffffffffffffffff"},
+		"bignum":          {BigInt(bigPositive), "c249010000000000000000"},
+		"largestNegative": {BigInt(largestNegative), "3bffffffffffffffff"},
+		"negativeBignum":  {BigInt(bigNegative), "c349010000000000000000"},
+		"minusOne":        {Num(-1), "20"},
+		"minus1000":       {Num(-1000), "3903e7"},
+		"floatZero":       {Float(0), "f90000"},
+		"negativeZero":    {Float(math.Copysign(0, -1)), "f98000"},
+		"floatOne":        {Float(1), "f93c00"},
+		"1.1":             {Float(1.1), "fb3ff199999999999a"},
+		"65504":           {Float(65504), "f97bff"},
+		"100000":          {Float(100000), "fa47c35000"},
+		"largestFloat32":  {Float(3.4028234663852886e+38), "fa7f7fffff"},
+		"1e300":           {Float(1e300), "fb7e37e43c8800759c"},
+		"smallestHalf":    {Float(5.960464477539063e-8), "f90001"},
+		"smallestNormal":  {Float(0.00006103515625), "f90400"},
+		"minus4":          {Float(-4), "f9c400"},
+		"minus4.1":        {Float(-4.1), "fbc010666666666666"},
+		"infinity":        {Float(math.Inf(1)), "f97c00"},
+		"minusInfinity":   {Float(math.Inf(-1)), "f9fc00"},
+		"nan":             {Float(math.NaN()), "f97e00"},
+		"emptyString":     {Str(""), "60"},
+		"string":          {Str("IETF"), "6449455446"},
+		"unicode":         {Str("ü"), "62c3bc"},
+		"notUTF8":         {Str("\xff"), "41ff"},
+		"emptyList":       {Lst(), "80"},
+		"list":            {Lst(Num(1), Num(2), Num(3)), "83010203"},
+		"nested":          {Lst(Num(1), Lst(Num(2), Num(3)), Lst(Num(4), Num(5))), "8301820203820405"},
+		"symbol":          {Sym("foo"), "d82763666f6f"},
+		"symbolNotUTF8":   {Sym("\xff"), "d82741ff"},
+		"rat":             {Rat(big.NewRat(-1, 3)), "d81e822003"},
+		"improperList":    {DottedLst([]Tree{Sym("a")}, Num(1)), "83d8276161f701"},
+	}
+	for name, c := range cases {
+		data, err := c.tree.MarshalCBOR()
+		got := hex.EncodeToString(data)
+		assert(t.Errorf, err == nil && got == c.hex, "%s: expected %s, got %s, %v", name, c.hex, got, err)
+
+		var back Tree
+		err = back.UnmarshalCBOR(data)
+		assert(t.Errorf, err == nil && Equal(back, c.tree), "%s: expected %v back, got %v, %v", name, c.tree, back, err)
+		assert(t.Errorf, back.tag == c.tree.tag, "%s: the shape changed from %v to %v", name, c.tree.tag, back.tag)
+	}
+}
+
+func TestCBORFloatsRoundTrip(t *testing.T) {
+	for _, f := range []float64{0.5, 1.0 / 3, 1e-10, 2.5e-8, 6.103515625e-05, 65505, 1e38, math.SmallestNonzeroFloat64, math.MaxFloat64} {
+		for _, f := range []float64{f, -f} {
+			data, _ := Float(f).MarshalCBOR()
+			var back Tree
+			err := back.UnmarshalCBOR(data)
+			assert(t.Errorf, err == nil && back.tag == symTreeFloat && back.float == f, "expected %v back, got %v, %v", f, back, err)
+		}
+	}
+}
+
+func TestDecodeCBOR(t *testing.T) {
+	cases := map[string]struct {
+		hex  string
+		tree string
+	}{
+		"booleans":          {"82f4f5", "(false true)"},
+		"null":              {"f6", "nil"},
+		"byteString":        {"4461626364", `"abcd"`},
+		"smallBignum":       {"c24101", "1"},
+		"nonPreferredInt":   {"1b0000000000000001", "1"},
+		"float64":           {"fb3ff8000000000000", "1.5"},
+		"map":               {"a2616102616101", `(("a" 1) ("a" 2))`},
+		"mapOrder":          {"a2026162d82761616161", `((2 "b") (a "a"))`},
+		"indefiniteArray":   {"9f018202039f0405ffff", "(1 (2 3) (4 5))"},
+		"emptyIndefinite":   {"9fff", "()"},
+		"indefiniteString":  {"7f657374726561646d696e67ff", `"streaming"`},
+		"indefiniteBytes":   {"5f42616243636465ff", `"abcde"`},
+		"indefiniteMap":     {"bf6161f5ff", `(("a" true))`},
+		"selfDescribed":     {"d9d9f783010203", "(1 2 3)"},
+		"indefiniteSymbol":  {"d8277f626162ff", "ab"},
+		"rationalOfBignums": {"d81e82c249010000000000000000c249010000000000000000", "1"},
+	}
+	for name, c := range cases {
+		data, _ := hex.DecodeString(c.hex)
+		var tree Tree
+		err := tree.UnmarshalCBOR(data)
+		got := sexpr{tree}.String()
+		assert(t.Errorf, err == nil && got == c.tree, "%s: expected %s, got %s, %v", name, c.tree, got, err)
+	}
+}
+
+func TestDecodeCBORErrors(t *testing.T) {
+	cases := map[string]string{
+		"empty":                "",
+		"truncatedHead":        "19",
+		"truncatedArray":       "8201",
+		"truncatedString":      "6461",
+		"reservedInfo":         "1c",
+		"indefiniteInt":        "1f",
+		"strayBreak":           "ff",
+		"leftoverData":         "0101",
+		"unknownTag":           "c074323031332d30332d32315432303a30343a30305a",
+		"unknownSimple":        "f0",
+		"symbolNotString":      "d82701",
+		"bignumNotBytes":       "c201",
+		"ratNotArray":          "d81e01",
+		"ratNotInts":           "d81e8261616162",
+		"ratZeroDenominator":   "d81e820100",
+		"topLevelUndefined":    "f7",
+		"undefinedFirst":       "82f701",
+		"undefinedNotLast":     "8401f70203",
+		"twoUndefined":         "8401f7f702",
+		"listTail":             "8301f780",
+		"undefinedInMap":       "a101f7",
+		"oddIndefiniteMap":     "bf01ff",
+		"mixedChunks":          "7f4161ff",
+		"nestedIndefiniteText": "7f7f6161ffff",
+		"unterminated":         "9f01",
+	}
+	for name, text := range cases {
+		data, _ := hex.DecodeString(text)
+		var tree Tree
+		err := tree.UnmarshalCBOR(data)
+		assert(t.Errorf, err != nil, "%s: expected an error, got %v", name, tree)
+	}
+}
+
+func TestCBORStream(t *testing.T) {
+	trees := []Tree{
+		Lst(Sym("define"), Lst(Sym("f"), Sym("x")), Lst(Sym("*"), Sym("x"), Float(2.5))),
+		Str("s"),
+		BigInt(hugeInt()),
+	}
+	var buf bytes.Buffer
+	enc := NewCBOREncoder(&buf)
+	for _, tree := range trees {
+		err := enc.Encode(tree)
+		assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+	}
+	err := enc.Encode(Lst(Tree{}))
+	assert(t.Errorf, err != nil, "expected an error for an invalid Tree")
+
+	readers := map[string]io.Reader{
+		"buffered": bytes.NewReader(buf.Bytes()),
+		"oneByte":  iotest.OneByteReader(bytes.NewReader(buf.Bytes())),
+	}
+	for name, src := range readers {
+		dec := NewCBORDecoder(src)
+		for i, tree := range trees {
+			got, err := dec.Decode()
+			assert(t.Errorf, err == nil && Equal(got, tree), "%s: tree %d: expected %v, got %v, %v", name, i, tree, got, err)
+		}
+		_, err := dec.Decode()
+		assert(t.Errorf, err == io.EOF, "%s: expected io.EOF at the end, got %v", name, err)
+	}
+
+	dec := NewCBORDecoder(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	dec.Decode()
+	dec.Decode()
+	_, err = dec.Decode()
+	assert(t.Errorf, errors.Is(err, io.ErrUnexpectedEOF), "expected an unexpected EOF, got %v", err)
+	_, again := dec.Decode()
+	assert(t.Errorf, again == err, "expected the error to stick, got %v", again)
+}
+
+func TestDecodeCBORDepthLimit(t *testing.T) {
+	nested := func(prefix string, depth int, last string) []byte {
+		data, _ := hex.DecodeString(strings.Repeat(prefix, depth) + last)
+		return data
+	}
+	var tree Tree
+	err := tree.UnmarshalCBOR(nested("81", maxCBORDepth-1, "00"))
+	assert(t.Errorf, err == nil, "unexpected error at the depth limit: %v", err)
+
+	cases := map[string][]byte{
+		"arrays":        nested("81", 5<<20, "00"),
+		"maps":          nested("a100", 1<<20, "00"),
+		"selfDescribed": nested("d9d9f7", 1<<20, "00"),
+		"justTooDeep":   nested("81", maxCBORDepth, "00"),
+	}
+	for name, data := range cases {
+		err := tree.UnmarshalCBOR(data)
+		assert(t.Errorf, err != nil, "%s: expected an error", name)
+	}
+}
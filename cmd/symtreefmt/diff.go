@@ -0,0 +1,202 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// contextLines is how many unchanged lines are shown around each change.
+const contextLines = 3
+
+// An edit is a line that is either kept, removed or added.
+type edit struct {
+	kind byte // one of ' ', '-' or '+'
+	line string
+}
+
+// diff returns a unified diff turning oldText into newText.
+// It is empty when the two are the same.
+func diff(oldName, newName string, oldText, newText []byte) []byte {
+	if bytes.Equal(oldText, newText) {
+		return nil
+	}
+	edits := lineEdits(splitLines(oldText), splitLines(newText))
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", oldName, newName)
+
+	// The line numbers each edit starts at, counting from 0.
+	oldAt, newAt := make([]int, len(edits)+1), make([]int, len(edits)+1)
+	for i, e := range edits {
+		oldAt[i+1], newAt[i+1] = oldAt[i], newAt[i]
+		if e.kind != '+' {
+			oldAt[i+1]++
+		}
+		if e.kind != '-' {
+			newAt[i+1]++
+		}
+	}
+
+	for start := 0; start < len(edits); {
+		if !nearChange(edits, start) {
+			start++
+			continue
+		}
+		end := start
+		for end < len(edits) && nearChange(edits, end) {
+			end++
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(oldAt[start], oldAt[end]), hunkRange(newAt[start], newAt[end]))
+		for _, e := range edits[start:end] {
+			out.WriteByte(e.kind)
+			out.WriteString(e.line)
+			if len(e.line) == 0 || e.line[len(e.line)-1] != '\n' {
+				out.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		start = end
+	}
+	return out.Bytes()
+}
+
+// nearChange checks if the i-th edit is a change or at most contextLines away from one.
+func nearChange(edits []edit, i int) bool {
+	for j := max(i-contextLines, 0); j <= min(i+contextLines, len(edits)-1); j++ {
+		if edits[j].kind != ' ' {
+			return true
+		}
+	}
+	return false
+}
+
+// hunkRange formats the lines from start to end, counting from 0, the way unified diffs do.
+func hunkRange(start, end int) string {
+	if start == end {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, end-start)
+}
+
+// splitLines splits text into lines, keeping the line breaks.
+func splitLines(text []byte) []string {
+	var lines []string
+	for len(text) > 0 {
+		i := bytes.IndexByte(text, '\n') + 1
+		if i == 0 {
+			i = len(text)
+		}
+		lines = append(lines, string(text[:i]))
+		text = text[i:]
+	}
+	return lines
+}
+
+// lineEdits finds the shortest way of turning a into b.
+// It uses the linear space variant of Myers' algorithm,
+// so that long files don't need a table with a cell for each pair of lines.
+// Within each run of changes, the removed lines come before the added ones.
+func lineEdits(a, b []string) []edit {
+	var edits []edit
+	appendLineEdits(&edits, a, b)
+
+	for start := 0; start < len(edits); start++ {
+		end := start
+		for end < len(edits) && edits[end].kind != ' ' {
+			end++
+		}
+		slices.SortStableFunc(edits[start:end], func(x, y edit) int { return cmp.Compare(y.kind, x.kind) })
+		start = end
+	}
+	return edits
+}
+
+// appendLineEdits appends the edits turning a into b, splitting the problem at a middle snake until it's trivial.
+func appendLineEdits(edits *[]edit, a, b []string) {
+	for len(a) > 0 && len(b) > 0 && a[0] == b[0] {
+		*edits = append(*edits, edit{' ', a[0]})
+		a, b = a[1:], b[1:]
+	}
+	common := 0
+	for common < len(a) && common < len(b) && a[len(a)-1-common] == b[len(b)-1-common] {
+		common++
+	}
+	suffix := a[len(a)-common:]
+	a, b = a[:len(a)-common], b[:len(b)-common]
+
+	switch {
+	case len(a) == 0:
+		for _, line := range b {
+			*edits = append(*edits, edit{'+', line})
+		}
+	case len(b) == 0:
+		for _, line := range a {
+			*edits = append(*edits, edit{'-', line})
+		}
+	default:
+		x, y, u, v := middleSnake(a, b)
+		appendLineEdits(edits, a[:x], b[:y])
+		for _, line := range a[x:u] {
+			*edits = append(*edits, edit{' ', line})
+		}
+		appendLineEdits(edits, a[u:], b[v:])
+	}
+
+	for _, line := range suffix {
+		*edits = append(*edits, edit{' ', line})
+	}
+}
+
+// middleSnake finds the run of equal lines, from (x, y) to (u, v), in the middle of a shortest edit path turning a into b.
+// It searches from both ends at once, keeping only the furthest points reached on each diagonal.
+func middleSnake(a, b []string) (x, y, u, v int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	limit := (n + m + 1) / 2
+	// forward[k+offset] is how far along a the forward search got on the diagonal x - y = k.
+	// backward is the same for the backward search, which works on a and b reversed.
+	offset := limit + 1
+	forward, backward := make([]int, 2*offset+1), make([]int, 2*offset+1)
+
+	for d := 0; d <= limit; d++ {
+		for k := -d; k <= d; k += 2 {
+			x := furthest(forward, offset, k, d)
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x, y = x+1, y+1
+			}
+			forward[k+offset] = x
+			if kb := delta - k; delta%2 != 0 && -d < kb && kb < d && x+backward[kb+offset] >= n {
+				return startX, startY, x, y
+			}
+		}
+		for k := -d; k <= d; k += 2 {
+			x := furthest(backward, offset, k, d)
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x, y = x+1, y+1
+			}
+			backward[k+offset] = x
+			if kf := delta - k; delta%2 == 0 && -d <= kf && kf <= d && x+forward[kf+offset] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+	panic("a shortest edit path was not found")
+}
+
+// furthest picks the furthest point on the diagonal k that a search can reach with d changes,
+// before following any equal lines.
+func furthest(reach []int, offset, k, d int) int {
+	if k == -d || k != d && reach[k-1+offset] < reach[k+1+offset] {
+		return reach[k+1+offset]
+	}
+	return reach[k-1+offset] + 1
+}
@@ -0,0 +1,169 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+// Command symtreefmt formats s-expression files.
+//
+// Usage:
+//
+//	symtreefmt [flags] [path ...]
+//
+// The flags are:
+//
+//	-d
+//		Do not print reformatted sources to standard output.
+//		If a file's formatting is different from symtreefmt's, print diffs
+//		to standard output.
+//	-l
+//		Do not print reformatted sources to standard output.
+//		If a file's formatting is different from symtreefmt's, print its name
+//		to standard output.
+//	-w
+//		Do not print reformatted sources to standard output.
+//		If a file's formatting is different from symtreefmt's, overwrite it
+//		with symtreefmt's version.
+//
+// Without paths, it formats standard input.
+// Given a directory, it formats the .sexpr files in it and in the directories below it,
+// skipping the ones whose names start with a dot.
+//
+// Comments are kept, as close to their original place as the layout allows.
+package main
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/szabba/symtree"
+)
+
+var (
+	list   = flag.Bool("l", false, "list files whose formatting differs from symtreefmt's")
+	write  = flag.Bool("w", false, "write result to (source) file instead of stdout")
+	doDiff = flag.Bool("d", false, "display diffs instead of rewriting files")
+)
+
+// printer is the canonical layout for s-expression files.
+var printer = symtree.Printer{Rules: symtree.LispIndentRules()}
+
+func main() {
+	flag.Usage = usage
+	flag.Parse()
+
+	exitCode := 0
+	report := func(err error) {
+		fmt.Fprintln(os.Stderr, err)
+		exitCode = 2
+	}
+
+	if flag.NArg() == 0 {
+		if *write {
+			report(fmt.Errorf("cannot use -w with standard input"))
+		} else if err := processFile("<standard input>", os.Stdin, os.Stdout, true); err != nil {
+			report(err)
+		}
+		os.Exit(exitCode)
+	}
+
+	for _, path := range flag.Args() {
+		if err := processPath(path, os.Stdout); err != nil {
+			report(err)
+		}
+	}
+	os.Exit(exitCode)
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: symtreefmt [flags] [path ...]\n")
+	flag.PrintDefaults()
+}
+
+// processPath formats the file at path, or all the s-expression files under it when it's a directory.
+// It carries on past errors, returning all of them.
+func processPath(path string, out io.Writer) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return formatPath(path, out)
+	}
+	var errs []error
+	err = filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			errs = append(errs, err)
+		} else if isSexprFile(d) {
+			if err := formatPath(path, out); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return nil
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// isSexprFile checks if a directory entry is a file symtreefmt should format when it walks a directory.
+func isSexprFile(d fs.DirEntry) bool {
+	name := d.Name()
+	return d.Type().IsRegular() && !strings.HasPrefix(name, ".") && strings.HasSuffix(name, ".sexpr")
+}
+
+func formatPath(path string, out io.Writer) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return processFile(path, f, out, false)
+}
+
+// processFile formats the contents of in.
+// Depending on the flags, it writes the result, the name or a diff to out, or overwrites the file.
+func processFile(name string, in io.Reader, out io.Writer, stdin bool) error {
+	src, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if _, err := printer.Reformat(&b, bytes.NewReader(src)); err != nil {
+		return fmt.Errorf("%s:%s", name, err)
+	}
+	res := b.Bytes()
+
+	if !bytes.Equal(src, res) {
+		if *list {
+			fmt.Fprintln(out, name)
+		}
+		if *write {
+			info, err := os.Stat(name)
+			if err != nil {
+				return err
+			}
+			if err := os.WriteFile(name, res, info.Mode().Perm()); err != nil {
+				return err
+			}
+		}
+		if *doDiff {
+			newName := name
+			if stdin {
+				name, newName = "<standard input>.orig", "<standard input>"
+			} else {
+				name, newName = name+".orig", name
+			}
+			out.Write(diff(name, newName, src, res))
+		}
+	}
+
+	if !*list && !*write && !*doDiff {
+		_, err = out.Write(res)
+	}
+	return err
+}
@@ -0,0 +1,197 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package main
+
+import (
+	"bytes"
+	"math/rand/v2"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const (
+	unformatted = "; rules\n(rule   (x)\n  ; why\n  y)\n"
+	formatted   = "; rules\n(rule (x)\n      ; why\n      y)\n"
+)
+
+func TestProcessFileWritesTheFormattedSource(t *testing.T) {
+	var out bytes.Buffer
+
+	err := processFile("in.sexpr", strings.NewReader(unformatted), &out, false)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out.String() != formatted {
+		t.Errorf("expected\n%s\ngot\n%s", formatted, out.String())
+	}
+}
+
+func TestProcessFileListsFilesThatNeedFormatting(t *testing.T) {
+	defer setFlag(list, true)()
+	var out bytes.Buffer
+
+	processFile("bad.sexpr", strings.NewReader(unformatted), &out, false)
+	processFile("good.sexpr", strings.NewReader(formatted), &out, false)
+
+	if out.String() != "bad.sexpr\n" {
+		t.Errorf("expected only %q to be listed, got %q", "bad.sexpr", out.String())
+	}
+}
+
+func TestProcessFileShowsDiffs(t *testing.T) {
+	defer setFlag(doDiff, true)()
+	var out bytes.Buffer
+
+	processFile("in.sexpr", strings.NewReader(unformatted), &out, false)
+
+	expected := "--- in.sexpr.orig\n+++ in.sexpr\n@@ -1,4 +1,4 @@\n ; rules\n-(rule   (x)\n-  ; why\n-  y)\n+(rule (x)\n+      ; why\n+      y)\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestProcessFileOverwritesFiles(t *testing.T) {
+	defer setFlag(write, true)()
+	path := filepath.Join(t.TempDir(), "in.sexpr")
+	os.WriteFile(path, []byte(unformatted), 0644)
+
+	err := processPath(path, &bytes.Buffer{})
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	contents, _ := os.ReadFile(path)
+	if string(contents) != formatted {
+		t.Errorf("expected the file to contain\n%s\ngot\n%s", formatted, contents)
+	}
+}
+
+func TestProcessPathFormatsTheSexprFilesInADirectory(t *testing.T) {
+	defer setFlag(list, true)()
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "sub"), 0755)
+	for _, name := range []string{"a.sexpr", "b.sexpr", "sub/c.sexpr", "sub/.hidden.sexpr", "other.txt"} {
+		os.WriteFile(filepath.Join(dir, name), []byte(unformatted), 0644)
+	}
+	os.WriteFile(filepath.Join(dir, "b.sexpr"), []byte(formatted), 0644)
+	var out bytes.Buffer
+
+	err := processPath(dir, &out)
+
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	expected := filepath.Join(dir, "a.sexpr") + "\n" + filepath.Join(dir, "sub", "c.sexpr") + "\n"
+	if out.String() != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, out.String())
+	}
+}
+
+func TestProcessPathCarriesOnPastErrorsInADirectory(t *testing.T) {
+	defer setFlag(list, true)()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "a.sexpr"), []byte("(a"), 0644)
+	os.WriteFile(filepath.Join(dir, "b.sexpr"), []byte(unformatted), 0644)
+	var out bytes.Buffer
+
+	err := processPath(dir, &out)
+
+	if err == nil || !strings.HasPrefix(err.Error(), filepath.Join(dir, "a.sexpr")+":") {
+		t.Errorf("expected an error in a.sexpr, got %v", err)
+	}
+	if out.String() != filepath.Join(dir, "b.sexpr")+"\n" {
+		t.Errorf("expected b.sexpr to be listed, got %q", out.String())
+	}
+}
+
+func TestProcessFileReportsSyntaxErrorsWithTheFileName(t *testing.T) {
+	err := processFile("in.sexpr", strings.NewReader("(a\n (b)"), &bytes.Buffer{}, false)
+
+	if err == nil || !strings.HasPrefix(err.Error(), "in.sexpr:1:1: ") {
+		t.Errorf("expected an error at in.sexpr:1:1, got %v", err)
+	}
+}
+
+func TestDiffShowsOnlyTheLinesAroundChanges(t *testing.T) {
+	old := "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n"
+	new := "1\n2\nthree\n4\n5\n6\n7\n8\n9\n10\neleven\n"
+
+	got := string(diff("a", "b", []byte(old), []byte(new)))
+
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,6 +1,6 @@\n 1\n 2\n-3\n+three\n 4\n 5\n 6\n" +
+		"@@ -8,3 +8,4 @@\n 8\n 9\n 10\n+eleven\n"
+	if got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestDiffMarksMissingNewlineAtEndOfFile(t *testing.T) {
+	got := string(diff("a", "b", []byte("x"), []byte("x\n")))
+
+	expected := "--- a\n+++ b\n@@ -1,1 +1,1 @@\n-x\n\\ No newline at end of file\n+x\n"
+	if got != expected {
+		t.Errorf("expected\n%s\ngot\n%s", expected, got)
+	}
+}
+
+func TestLineEditsKeepALongestCommonSubsequence(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	for range 500 {
+		a, b := randomLines(rnd), randomLines(rnd)
+
+		edits := lineEdits(a, b)
+
+		var gotA, gotB []string
+		kept := 0
+		for _, e := range edits {
+			if e.kind != '+' {
+				gotA = append(gotA, e.line)
+			}
+			if e.kind != '-' {
+				gotB = append(gotB, e.line)
+			}
+			if e.kind == ' ' {
+				kept++
+			}
+		}
+		if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+			t.Fatalf("the edits %v don't turn %q into %q", edits, a, b)
+		}
+		if lcs := lcsLength(a, b); kept != lcs {
+			t.Fatalf("expected the edits turning %q into %q to keep %d lines, they keep %d", a, b, lcs, kept)
+		}
+	}
+}
+
+func randomLines(rnd *rand.Rand) []string {
+	lines := make([]string, rnd.IntN(12))
+	for i := range lines {
+		lines[i] = string(rune('a' + rnd.IntN(3)))
+	}
+	return lines
+}
+
+// lcsLength computes the length of the longest common subsequence the slow way.
+func lcsLength(a, b []string) int {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	if a[0] == b[0] {
+		return 1 + lcsLength(a[1:], b[1:])
+	}
+	return max(lcsLength(a[1:], b), lcsLength(a, b[1:]))
+}
+
+// setFlag sets a boolean flag and returns a function that restores its old value.
+func setFlag(flag *bool, value bool) func() {
+	old := *flag
+	*flag = value
+	return func() { *flag = old }
+}
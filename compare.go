@@ -0,0 +1,202 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"cmp"
+	"math"
+	"math/big"
+	"slices"
+	"strings"
+)
+
+// Compare orders Trees.
+// It returns -1 if a comes before b, +1 if a comes after b and 0 if they are Equal.
+// It can be passed to slices.SortFunc, slices.BinarySearchFunc and the like.
+//
+// Trees of different shapes are ordered as
+//
+//	invalid < number < string < symbol < list
+//
+// Numbers are ordered by value, with NaN before all other numbers.
+// Numbers of different kinds with the same value are ordered as
+//
+//	int < big integer < rational < float
+//
+// so that Compare only returns 0 for Equal Trees.
+//
+// Strings and symbols are ordered byte-wise, the same as strings.Compare does.
+// Lists are ordered lexicographically by their elements, with a list coming after all of its prefixes.
+// Lists with the same elements are ordered by their tails, with proper lists coming first.
+func Compare(a, b Tree) int {
+	if a.node != nil && a.node == b.node {
+		return 0
+	}
+	if c := cmp.Compare(shapeRank(a), shapeRank(b)); c != 0 {
+		return c
+	}
+	switch {
+	case isNumber(a):
+		return compareNumbers(a, b)
+	case a.tag == symTreeString:
+		return strings.Compare(a.symbol, b.symbol)
+	case a.tag == symTreeSymbol:
+		return strings.Compare(a.symbol, b.symbol)
+	case a.tag == symTreeList:
+		return compareLists(a.list, b.list)
+	}
+	return 0
+}
+
+// shapeRank puts Trees of different shapes in the order Compare documents.
+func shapeRank(t Tree) int {
+	switch {
+	case isNumber(t):
+		return 1
+	case t.tag == symTreeString:
+		return 2
+	case t.tag == symTreeSymbol:
+		return 3
+	case t.tag == symTreeList:
+		return 4
+	}
+	return 0
+}
+
+func isNumber(t Tree) bool {
+	switch t.tag {
+	case symTreeNumber, symTreeFloat, symTreeBigInt, symTreeRat:
+		return true
+	}
+	return false
+}
+
+// numberKindRank orders numbers of different kinds that have the same value.
+func numberKindRank(t Tree) int {
+	switch t.tag {
+	case symTreeBigInt:
+		return 1
+	case symTreeRat:
+		return 2
+	case symTreeFloat:
+		return 3
+	}
+	return 0
+}
+
+func compareNumbers(a, b Tree) int {
+	if c := compareNumberValues(a, b); c != 0 {
+		return c
+	}
+	return cmp.Compare(numberKindRank(a), numberKindRank(b))
+}
+
+func compareNumberValues(a, b Tree) int {
+	switch {
+	case a.tag == symTreeNumber && b.tag == symTreeNumber:
+		return cmp.Compare(a.number, b.number)
+	case a.tag == symTreeFloat && b.tag == symTreeFloat:
+		return cmp.Compare(a.float, b.float)
+	}
+	if c, ok := compareNonFinite(a, b); ok {
+		return c
+	}
+	return exactValue(a).Cmp(exactValue(b))
+}
+
+// compareNonFinite compares two numbers when at least one of them is a NaN or an infinity.
+// Otherwise, the second result is false.
+func compareNonFinite(a, b Tree) (int, bool) {
+	fa, fb := 0.0, 0.0
+	if a.tag == symTreeFloat {
+		fa = a.float
+	}
+	if b.tag == symTreeFloat {
+		fb = b.float
+	}
+	if math.IsNaN(fa) || math.IsNaN(fb) || math.IsInf(fa, 0) || math.IsInf(fb, 0) {
+		return cmp.Compare(fa, fb), true
+	}
+	return 0, false
+}
+
+// exactValue converts a finite number to a big.Rat without losing precision.
+func exactValue(t Tree) *big.Rat {
+	switch t.tag {
+	case symTreeFloat:
+		return new(big.Rat).SetFloat64(t.float)
+	case symTreeBigInt:
+		return new(big.Rat).SetInt(t.bigInt)
+	case symTreeRat:
+		return t.rat
+	}
+	return new(big.Rat).SetInt64(int64(t.number))
+}
+
+func compareLists(a, b List) int {
+	as, bs := a.cursor(), b.cursor()
+	for range min(a.Len(), b.Len()) {
+		x, _ := as.next()
+		y, _ := bs.next()
+		if c := Compare(x, y); c != 0 {
+			return c
+		}
+	}
+	if c := cmp.Compare(a.Len(), b.Len()); c != 0 {
+		return c
+	}
+	aTail, aImproper := a.Tail()
+	bTail, bImproper := b.Tail()
+	if aImproper && bImproper {
+		return Compare(aTail, bTail)
+	}
+	return cmp.Compare(boolRank(aImproper), boolRank(bImproper))
+}
+
+func boolRank(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// Sorted returns a List with the same elements and tail as the receiver, with the elements ordered by Compare.
+// Elements that are Equal keep their relative order.
+// Call Tree on the result to get a sorted list Tree.
+func (l List) Sorted() List {
+	elems := l.trees()
+	slices.SortStableFunc(elems, Compare)
+	sorted := listOf(elems)
+	sorted.tail = l.tail
+	return sorted
+}
+
+// IsSorted reports whether the elements of the List are ordered by Compare.
+func (l List) IsSorted() bool {
+	var prev Tree
+	for i, t := range l.All() {
+		if i > 0 && Compare(prev, t) > 0 {
+			return false
+		}
+		prev = t
+	}
+	return true
+}
+
+// Search looks for t in a List sorted by Compare.
+// It returns the position where t is, or would be inserted if it is missing,
+// and whether it was found.
+func (l List) Search(t Tree) (int, bool) {
+	i, j := 0, l.Len()
+	for i < j {
+		h := int(uint(i+j) >> 1)
+		if Compare(l.At(h), t) < 0 {
+			i = h + 1
+		} else {
+			j = h
+		}
+	}
+	return i, i < l.Len() && Compare(l.At(i), t) == 0
+}
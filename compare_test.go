@@ -0,0 +1,122 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"math"
+	"math/big"
+	"slices"
+	"testing"
+)
+
+// orderedTrees lists Trees in ascending order, with no two of them Equal.
+func orderedTrees() []Tree {
+	return []Tree{
+		{},
+		Float(math.NaN()),
+		Float(math.Inf(-1)),
+		BigInt(new(big.Int).Neg(hugeInt())),
+		Num(-1),
+		Rat(big.NewRat(-1, 2)),
+		Float(-0.5),
+		Num(0),
+		Float(0),
+		Num(1),
+		Float(1),
+		Rat(big.NewRat(3, 2)),
+		BigInt(hugeInt()),
+		Float(math.Inf(1)),
+		Str(""),
+		Str("a"),
+		Str("b"),
+		Sym(""),
+		Sym("a"),
+		Sym("ab"),
+		Sym("b"),
+		Lst(),
+		Lst(Num(1)),
+		DottedLst([]Tree{Num(1)}, Num(2)),
+		DottedLst([]Tree{Num(1)}, Sym("a")),
+		Lst(Num(1), Num(2)),
+		Lst(Num(2)),
+		Lst(Sym("a")),
+		Lst(Lst()),
+	}
+}
+
+func TestCompareOrdersTrees(t *testing.T) {
+	trees := orderedTrees()
+	for i, a := range trees {
+		for j, b := range trees {
+			expected := 0
+			if i < j {
+				expected = -1
+			} else if i > j {
+				expected = 1
+			}
+			actual := Compare(a, b)
+			assert(t.Errorf, actual == expected, "Compare(%v, %v): expected %d, got %d", a, b, expected, actual)
+		}
+	}
+}
+
+func TestCompareIsConsistentWithEqual(t *testing.T) {
+	cases := map[string][2]Tree{
+		"nan":      {Float(math.NaN()), Float(-math.NaN())},
+		"zero":     {Float(0), Float(math.Copysign(0, -1))},
+		"rational": {Rat(big.NewRat(2, 4)), Rat(big.NewRat(1, 2))},
+		"list":     {Lst(Sym("+"), Num(1), Str("2")), Lst(Sym("+"), Num(1), Str("2"))},
+		"interned": {NewFactory().Lst(Sym("a")), Lst(Sym("a"))},
+	}
+	for name, pair := range cases {
+		assert(t.Errorf, Compare(pair[0], pair[1]) == 0, "%s: %v and %v should compare as equal", name, pair[0], pair[1])
+	}
+}
+
+func TestCompareSortsWithSlicesSortFunc(t *testing.T) {
+	expected := orderedTrees()
+	trees := slices.Clone(expected)
+	slices.Reverse(trees)
+
+	slices.SortFunc(trees, Compare)
+
+	assert(t.Errorf, slices.EqualFunc(trees, expected, Equal), "expected %v, got %v", expected, trees)
+}
+
+func TestListSorted(t *testing.T) {
+	var l List
+	Lst(Sym("b"), Num(2), Lst(), Num(1), Str("a")).IfList(func(list List) { l = list })
+
+	sorted := l.Sorted()
+
+	expected := Lst(Num(1), Num(2), Str("a"), Sym("b"), Lst())
+	assert(t.Errorf, Equal(sorted.Tree(), expected), "expected %v, got %v", expected, sorted.Tree())
+	improper := DottedLst([]Tree{Num(2), Num(1)}, Sym("t"))
+	improper.IfList(func(l List) {
+		expected := DottedLst([]Tree{Num(1), Num(2)}, Sym("t"))
+		assert(t.Errorf, Equal(l.Sorted().Tree(), expected), "expected %v, got %v", expected, l.Sorted().Tree())
+	})
+	assert(t.Errorf, !l.IsSorted(), "%v should not be sorted", l)
+	assert(t.Errorf, sorted.IsSorted(), "%v should be sorted", sorted)
+}
+
+func TestListSearch(t *testing.T) {
+	l := Lst(Num(1), Num(3), Sym("a")).list
+	cases := map[string]struct {
+		tree  Tree
+		index int
+		found bool
+	}{
+		"first":   {Num(1), 0, true},
+		"last":    {Sym("a"), 2, true},
+		"missing": {Num(2), 1, false},
+		"before":  {Num(0), 0, false},
+		"after":   {Lst(), 3, false},
+	}
+	for name, c := range cases {
+		index, found := l.Search(c.tree)
+		assert(t.Errorf, index == c.index && found == c.found, "%s: expected (%d, %t), got (%d, %t)", name, c.index, c.found, index, found)
+	}
+}
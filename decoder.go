@@ -0,0 +1,58 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bufio"
+	"io"
+)
+
+// A Decoder reads a sequence of s-expression forms from an input stream.
+// The forms use the same syntax as for ReadSexpr.
+type Decoder struct {
+	r reader
+}
+
+// NewDecoder creates a Decoder that reads from src.
+// The Decoder buffers src, unless it is an io.RuneScanner already.
+func NewDecoder(src io.Reader) *Decoder {
+	scanner, ok := src.(io.RuneScanner)
+	if !ok {
+		scanner = bufio.NewReader(src)
+	}
+	return &Decoder{r: newReader(scanner)}
+}
+
+// RecordSpans makes the Decoder attach a Span to every Tree it reads from now on, including subtrees.
+// The spans use file as the file name.
+func (d *Decoder) RecordSpans(file string) {
+	d.r.spans = true
+	d.r.file = file
+}
+
+// Decode reads the next form from the input.
+//
+// When there are no forms left, the error is io.EOF.
+// Malformed input results in a SyntaxError, with positions counted from the start of the input.
+// In particular, when the input ends in the middle of a form, the error's cause is io.ErrUnexpectedEOF.
+// After an error, all further calls to Decode fail the same way.
+func (d *Decoder) Decode() (Tree, error) {
+	if !d.More() {
+		return Tree{}, d.r.err
+	}
+	tree, err := d.r.parse()
+	if err == io.EOF {
+		// The form was an atom ended by the end of the input.
+		err = nil
+	}
+	return tree, err
+}
+
+// More checks whether there is another form to decode.
+// Only whitespace and comments are skipped over to find out.
+func (d *Decoder) More() bool {
+	d.r.skipAtmosphere()
+	return d.r.more()
+}
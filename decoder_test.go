@@ -0,0 +1,179 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecoderReadsAllTheForms(t *testing.T) {
+	type testcase struct {
+		input string
+		trees []Tree
+	}
+	cases := map[string]testcase{
+		"noInput":        {"", nil},
+		"onlyAtmosphere": {"  ; comment\n #| block |# ", nil},
+		"oneAtom":        {"x", []Tree{Sym("x")}},
+		"twoLists":       {"(+) (abba u2 rem)", []Tree{Lst(Sym("+")), Lst(Sym("abba"), Sym("u2"), Sym("rem"))}},
+		"adjacentForms":  {`(a)b"c"(d)`, []Tree{Lst(Sym("a")), Sym("b"), Str("c"), Lst(Sym("d"))}},
+		"atomsAndLists":  {"1 (2) 3", []Tree{Num(1), Lst(Num(2)), Num(3)}},
+		"trailingSpace":  {"a b\n", []Tree{Sym("a"), Sym("b")}},
+		"comments":       {"a ; one\n#| two |# b #;c", []Tree{Sym("a"), Sym("b")}},
+		"hashAtoms":      {"#a # #", []Tree{Sym("#a"), Sym("#"), Sym("#")}},
+	}
+
+	for name, kase := range cases {
+		t.Run(name, func(t *testing.T) {
+			dec := NewDecoder(strings.NewReader(kase.input))
+
+			var trees []Tree
+			for dec.More() {
+				tree, err := dec.Decode()
+				assert(t.Fatalf, err == nil, "unexpected error: %s", err)
+				trees = append(trees, tree)
+			}
+			_, err := dec.Decode()
+
+			assert(t.Errorf, err == io.EOF, "expected %q after the last form, got %q", io.EOF, err)
+			assert(t.Fatalf, len(kase.trees) == len(trees), "expected %d forms, got %d", len(kase.trees), len(trees))
+			for i := range trees {
+				assert(t.Errorf, Equal(kase.trees[i], trees[i]), "form %d: expected %v, got %v", i, kase.trees[i], trees[i])
+			}
+		})
+	}
+}
+
+func TestDecoderReportsTruncatedInput(t *testing.T) {
+	inputs := []string{"(a) (b", `(a) "b`, "(a) #| b", "(a) #;", "(a) |b"}
+	for _, input := range inputs {
+		dec := NewDecoder(strings.NewReader(input))
+
+		first, err := dec.Decode()
+		assert(t.Errorf, err == nil, "%q: unexpected error: %s", input, err)
+		assert(t.Errorf, Equal(Lst(Sym("a")), first), "%q: expected %v, got %v", input, Lst(Sym("a")), first)
+
+		_, err = dec.Decode()
+		cause := errors.Cause(err)
+		assert(t.Errorf, io.ErrUnexpectedEOF == cause, "%q: expected error cause %q, got %q", input, io.ErrUnexpectedEOF, cause)
+		syntaxErr, typeOK := err.(SyntaxError)
+		assert(t.Fatalf, typeOK, "%q: error returned should implement SyntaxError", input)
+		expectedPos := Position{Offset: 4, Line: 1, Column: 5}
+		assert(t.Errorf, expectedPos == syntaxErr.Position(), "%q: expected position %v, got %v", input, expectedPos, syntaxErr.Position())
+
+		_, laterErr := dec.Decode()
+		assert(t.Errorf, err == laterErr, "%q: the error should stay the same, got %q", input, laterErr)
+	}
+}
+
+func TestDecoderReadsManyForms(t *testing.T) {
+	const n = 5000
+	var b bytes.Buffer
+	for i := 0; i < n; i++ {
+		WriteSexpr(&b, Lst(Sym("form"), Num(i)))
+		b.WriteString("\n")
+	}
+
+	dec := NewDecoder(&b)
+	count := 0
+	for ; dec.More(); count++ {
+		tree, err := dec.Decode()
+		assert(t.Fatalf, err == nil, "unexpected error: %s", err)
+		assert(t.Fatalf, Equal(Lst(Sym("form"), Num(count)), tree), "form %d: got %v", count, tree)
+	}
+
+	assert(t.Errorf, n == count, "expected %d forms, got %d", n, count)
+}
+
+func TestReadSexprCanBeCalledRepeatedly(t *testing.T) {
+	src := strings.NewReader("(+) (abba u2 rem)")
+
+	first, err := ReadSexpr(src)
+	assert(t.Errorf, err == nil, "unexpected error: %s", err)
+	second, err := ReadSexpr(src)
+	assert(t.Errorf, err == nil, "unexpected error: %s", err)
+
+	assert(t.Errorf, Equal(Lst(Sym("+")), first), "expected %v, got %v", Lst(Sym("+")), first)
+	expected := Lst(Sym("abba"), Sym("u2"), Sym("rem"))
+	assert(t.Errorf, Equal(expected, second), "expected %v, got %v", expected, second)
+}
+
+func TestDecoderCountsPositionsFromTheStartOfTheInput(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("(a)\n; comment\n(b \"λ\") )"))
+	dec.Decode()
+	dec.Decode()
+
+	_, err := dec.Decode()
+
+	syntaxErr, typeOK := err.(SyntaxError)
+	assert(t.Fatalf, typeOK, "error returned should implement SyntaxError, got %v", err)
+	expectedPos := Position{Offset: 23, Line: 3, Column: 9}
+	assert(t.Errorf, expectedPos == syntaxErr.Position(), "expected position %v, got %v", expectedPos, syntaxErr.Position())
+	assert(t.Errorf, ')' == syntaxErr.Found(), "expected to find %q, not %q", ')', syntaxErr.Found())
+}
+
+func TestDecoderRecordsSpansOfAllSubtrees(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("; rules\n(rule\n  (x \"λ\"))"))
+	dec.RecordSpans("rules.sexpr")
+
+	tree, err := dec.Decode()
+	assert(t.Fatalf, err == nil, "unexpected error: %s", err)
+
+	var inner, str Tree
+	tree.IfList(func(l List) {
+		inner = l.At(1)
+		inner.IfList(func(l List) { str = l.At(1) })
+	})
+	cases := map[string]struct {
+		tree Tree
+		span Span
+	}{
+		"outer":  {tree, Span{"rules.sexpr", Position{8, 2, 1}, Position{25, 3, 11}}},
+		"inner":  {inner, Span{"rules.sexpr", Position{16, 3, 3}, Position{24, 3, 10}}},
+		"string": {str, Span{"rules.sexpr", Position{19, 3, 6}, Position{23, 3, 9}}},
+	}
+	for name, kase := range cases {
+		span, ok := kase.tree.Span()
+		assert(t.Errorf, ok, "%s: the tree should have a span", name)
+		assert(t.Errorf, kase.span == span, "%s: expected span %v, got %v", name, kase.span, span)
+	}
+}
+
+func TestSpansAreKeptByPatternMatches(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("(+ 1 (* 2 3))"))
+	dec.RecordSpans("")
+	tree, _ := dec.Decode()
+	pattern := FromExample([]string{"x", "y"}, Lst(Sym("+"), Sym("x"), Sym("y")))
+
+	match := map[string]Tree{}
+	err := pattern.Match(tree, match)
+
+	assert(t.Fatalf, err == nil, "unexpected error: %s", err)
+	span, ok := match["y"].Span()
+	expected := Span{Start: Position{5, 1, 6}, End: Position{12, 1, 13}}
+	assert(t.Errorf, ok && expected == span, "expected span %v, got %v", expected, span)
+}
+
+func TestSpansAreOptIn(t *testing.T) {
+	tree, _ := NewDecoder(strings.NewReader("(a b)")).Decode()
+
+	_, ok := tree.Span()
+
+	assert(t.Errorf, !ok, "the tree should not have a span")
+}
+
+func TestSpansDoNotAffectEquality(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("(a 1 \"b\")"))
+	dec.RecordSpans("")
+	tree, _ := dec.Decode()
+
+	expected := Lst(Sym("a"), Num(1), Str("b"))
+	assert(t.Errorf, Equal(expected, tree), "expected %v, got %v", expected, tree)
+}
@@ -4,7 +4,11 @@
 
 package symtree
 
-import "fmt"
+import (
+	"fmt"
+	"io"
+	"reflect"
+)
 
 // A SymbolAlreadyBound error means an attempt to override a binding has happened.
 type SymbolAlreadyBound interface {
@@ -88,13 +92,165 @@ type LengthMismatch interface {
 
 type lenMismatch struct {
 	expected, got int
+	// atLeast is set for patterns with a tail, which also match longer lists.
+	atLeast bool
 }
 
 var _ LengthMismatch = lenMismatch{}
 
 func (lm lenMismatch) Error() string {
+	if lm.atLeast {
+		return fmt.Sprintf("expected a list of length at least %d, not %d", lm.ExpectedLen(), lm.GotLen())
+	}
 	return fmt.Sprintf("expected a list of length %d, not %d", lm.ExpectedLen(), lm.GotLen())
 }
 
 func (lm lenMismatch) ExpectedLen() int { return lm.expected }
 func (lm lenMismatch) GotLen() int      { return lm.got }
+
+// A TailMismatch error means that a list is improper and a pattern is for a proper list.
+type TailMismatch interface {
+	error
+	UnexpectedTail() Tree
+}
+
+type tailMismatch struct {
+	tail Tree
+}
+
+var _ TailMismatch = tailMismatch{}
+
+func (tm tailMismatch) Error() string {
+	return fmt.Sprintf("expected a proper list, not one with the tail %v", tm.UnexpectedTail())
+}
+
+func (tm tailMismatch) UnexpectedTail() Tree { return tm.tail }
+
+// A SyntaxError means that s-expression text is malformed.
+//
+// When the text ends too early, the error's cause is io.ErrUnexpectedEOF.
+// The position then points back at the start of whatever was left unclosed.
+type SyntaxError interface {
+	error
+	// Position says where the problem is.
+	Position() Position
+	// Found returns the offending rune, or -1 if it's the end of the input.
+	Found() rune
+	// Expected describes what should have been there instead.
+	Expected() string
+}
+
+// endOfInput is what a SyntaxError finds when the input ends too early.
+const endOfInput rune = -1
+
+type syntaxError struct {
+	pos      Position
+	found    rune
+	expected string
+	problem  string
+}
+
+var _ SyntaxError = syntaxError{}
+
+func (se syntaxError) Error() string {
+	found := fmt.Sprintf("%q", se.Found())
+	if se.Found() == endOfInput {
+		found = "end of input"
+	}
+	return fmt.Sprintf("%s: %s: expected %s, found %s", se.Position(), se.problem, se.Expected(), found)
+}
+
+func (se syntaxError) Position() Position { return se.pos }
+func (se syntaxError) Found() rune        { return se.found }
+func (se syntaxError) Expected() string   { return se.expected }
+
+type unexpectedEOF struct {
+	syntaxError
+}
+
+var _ SyntaxError = unexpectedEOF{}
+
+func (ue unexpectedEOF) Cause() error  { return io.ErrUnexpectedEOF }
+func (ue unexpectedEOF) Unwrap() error { return io.ErrUnexpectedEOF }
+
+// A NoSuchPath error means that a Path leads to a subtree that doesn't exist.
+type NoSuchPath interface {
+	error
+	// Path returns the part of the Path that could be followed, together with the index that couldn't.
+	Path() Path
+	// Subtree returns the Tree that should have had an element at that index.
+	Subtree() Tree
+}
+
+type noSuchPath struct {
+	path    Path
+	subtree Tree
+}
+
+var _ NoSuchPath = noSuchPath{}
+
+func (nsp noSuchPath) Error() string {
+	parent := nsp.path[:len(nsp.path)-1]
+	if nsp.subtree.tag != symTreeList {
+		return fmt.Sprintf("no subtree at %s: %s is not a list", nsp.path, parent)
+	}
+	return fmt.Sprintf("no subtree at %s: %s has %d elements", nsp.path, parent, nsp.subtree.list.Len())
+}
+
+func (nsp noSuchPath) Path() Path    { return nsp.path }
+func (nsp noSuchPath) Subtree() Tree { return nsp.subtree }
+
+// An UnmarshalError means that a subtree couldn't be stored in a Go value.
+type UnmarshalError interface {
+	error
+	// Path says where the subtree is in the Tree being unmarshaled.
+	Path() Path
+	// Subtree returns the subtree itself.
+	Subtree() Tree
+	// Type returns the type of the Go value it should have been stored in.
+	Type() reflect.Type
+}
+
+type unmarshalError struct {
+	path    Path
+	tree    Tree
+	typ     reflect.Type
+	problem string
+	cause   error
+}
+
+var _ UnmarshalError = unmarshalError{}
+
+func (ue unmarshalError) Error() string {
+	msg := fmt.Sprintf("cannot unmarshal %v at %s into a Go value of type %v", ue.tree, ue.path, ue.typ)
+	if ue.problem != "" {
+		msg += ": " + ue.problem
+	}
+	if ue.cause != nil {
+		msg += ": " + ue.cause.Error()
+	}
+	return msg
+}
+
+func (ue unmarshalError) Path() Path         { return ue.path }
+func (ue unmarshalError) Subtree() Tree      { return ue.tree }
+func (ue unmarshalError) Type() reflect.Type { return ue.typ }
+func (ue unmarshalError) Unwrap() error      { return ue.cause }
+
+// An UnsupportedType error means that a Go value has a type Marshal can't convert to a Tree.
+type UnsupportedType interface {
+	error
+	Type() reflect.Type
+}
+
+type unsupportedType struct {
+	typ reflect.Type
+}
+
+var _ UnsupportedType = unsupportedType{}
+
+func (ut unsupportedType) Error() string {
+	return fmt.Sprintf("cannot marshal a Go value of type %v", ut.typ)
+}
+
+func (ut unsupportedType) Type() reflect.Type { return ut.typ }
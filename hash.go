@@ -0,0 +1,141 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"hash/fnv"
+	"math"
+	"math/big"
+)
+
+// Hash computes a structural hash of a Tree.
+// Trees that are Equal have the same hash.
+//
+// The hash does not depend on the process or the platform,
+// so it can be stored and compared with hashes computed elsewhere.
+// Integers hash by their value, whether they fit in an int or not.
+func Hash(t Tree) uint64 {
+	h := hasher{fnv.New64a()}
+	h.Tree(t)
+	return h.Sum64()
+}
+
+type hasher struct {
+	hash.Hash64
+}
+
+// Shape codes that start the hash of each Tree.
+// They are fixed, unlike the tags of Trees, so that changes to the tags don't change the hashes.
+// Integers have one code, whether they fit in an int or not,
+// because which ones fit depends on the platform.
+const (
+	hashInvalid = 0
+	hashSymbol  = 1
+	hashInteger = 2
+	hashList    = 3
+	hashFloat   = 4
+	hashRat     = 6
+	hashString  = 7
+)
+
+func (h hasher) Tree(t Tree) {
+	t.IfInvalid(func() { h.Uint(hashInvalid) })
+	t.IfSymbol(func(name string) {
+		h.Uint(hashSymbol)
+		h.String(name)
+	})
+	t.IfNumber(func(n int) {
+		h.Uint(hashInteger)
+		h.Int(n)
+	})
+	t.IfBigInt(func(n *big.Int) {
+		h.Uint(hashInteger)
+		h.BigInt(n)
+	})
+	t.IfFloat(func(f float64) {
+		h.Uint(hashFloat)
+		h.Float(f)
+	})
+	t.IfRat(func(r *big.Rat) {
+		h.Uint(hashRat)
+		h.BigInt(r.Num())
+		h.BigInt(r.Denom())
+	})
+	t.IfString(func(text string) {
+		h.Uint(hashString)
+		h.String(text)
+	})
+	t.IfList(func(l List) {
+		h.Uint(hashList)
+		h.List(l)
+	})
+}
+
+// List hashes the length of an improper list complemented,
+// so that it tells where the elements end and the tail begins.
+func (h hasher) List(l List) {
+	tail, improper := l.Tail()
+	if improper {
+		h.Uint(^uint64(l.Len()))
+	} else {
+		h.Uint(uint64(l.Len()))
+	}
+	for elem := range l.Values() {
+		h.Tree(elem)
+	}
+	if improper {
+		h.Tree(tail)
+	}
+}
+
+// Float hashes all NaNs the same and zero the same as negative zero,
+// because that's how Equal compares floats.
+func (h hasher) Float(f float64) {
+	switch {
+	case math.IsNaN(f):
+		f = math.NaN()
+	case f == 0:
+		f = 0
+	}
+	h.Uint(math.Float64bits(f))
+}
+
+func (h hasher) BigInt(n *big.Int) {
+	h.Uint(uint64(n.Sign() + 1))
+	h.Bytes(n.Bytes())
+}
+
+// Int hashes an int the same way BigInt hashes a big integer of the same value.
+func (h hasher) Int(n int) {
+	sign, abs := 1, uint64(n)
+	if n < 0 {
+		sign, abs = -1, -abs
+	} else if n == 0 {
+		sign = 0
+	}
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], abs)
+	h.Uint(uint64(sign + 1))
+	h.Bytes(bytes.TrimLeft(buf[:], "\x00"))
+}
+
+func (h hasher) String(s string) {
+	h.Uint(uint64(len(s)))
+	h.Write([]byte(s))
+}
+
+func (h hasher) Bytes(b []byte) {
+	h.Uint(uint64(len(b)))
+	h.Write(b)
+}
+
+func (h hasher) Uint(n uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], n)
+	h.Write(buf[:])
+}
@@ -0,0 +1,71 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestEqualTreesHaveEqualHashes(t *testing.T) {
+	withSpans := func(input string) Tree {
+		dec := NewDecoder(strings.NewReader(input))
+		dec.RecordSpans("")
+		tree, _ := dec.Decode()
+		return tree
+	}
+	cases := map[string][2]Tree{
+		"invalid":  {Tree{}, Lst().list.At(0)},
+		"symbol":   {Sym("x"), Sym("x")},
+		"nan":      {Float(math.NaN()), Float(-math.NaN())},
+		"zero":     {Float(0), Float(math.Copysign(0, -1))},
+		"bigInt":   {BigInt(hugeInt()), BigInt(new(big.Int).Set(hugeInt()))},
+		"rational": {Rat(big.NewRat(2, 4)), Rat(big.NewRat(1, 2))},
+		"list":     {Lst(Sym("+"), Num(1), Str("2")), Lst(Sym("+"), Num(1), Str("2"))},
+		"spans":    {withSpans("(a (b 1.5))"), Lst(Sym("a"), Lst(Sym("b"), Float(1.5)))},
+		"improper": {withSpans("(a b . c)"), DottedLst([]Tree{Sym("a"), Sym("b")}, Sym("c"))},
+	}
+	for name, pair := range cases {
+		assert(t.Errorf, Equal(pair[0], pair[1]), "%s: %v and %v should be equal", name, pair[0], pair[1])
+		assert(t.Errorf, Hash(pair[0]) == Hash(pair[1]), "%s: %v and %v should have the same hash", name, pair[0], pair[1])
+	}
+}
+
+func TestDifferentTreesHaveDifferentHashes(t *testing.T) {
+	trees := []Tree{
+		Tree{}, Sym(""), Str(""), Lst(), Lst(Lst()),
+		Sym("1"), Str("1"), Num(1), Float(1), Rat(big.NewRat(1, 2)),
+		Num(-1), BigInt(hugeInt()), BigInt(new(big.Int).Neg(hugeInt())),
+		Lst(Sym("ab")), Lst(Sym("a"), Sym("b")), Lst(Lst(Sym("a")), Sym("b")), Lst(Sym("a"), Lst(Sym("b"))),
+		DottedLst([]Tree{Sym("a")}, Sym("b")), Lst(DottedLst([]Tree{Sym("a")}, Sym("b")), Sym("c")),
+		DottedLst([]Tree{Lst(Sym("a")), Sym("b")}, Sym("c")),
+	}
+	seen := map[uint64]Tree{}
+	for _, tree := range trees {
+		h := Hash(tree)
+		other, clash := seen[h]
+		assert(t.Errorf, !clash, "%v and %v have the same hash %x", tree, other, h)
+		seen[h] = tree
+	}
+}
+
+func TestHashIsStable(t *testing.T) {
+	tree := Lst(Sym("+"), Num(1), Lst(Str("two"), Float(3)))
+
+	const expected uint64 = 0x4acbed04abeac09f
+	assert(t.Errorf, expected == Hash(tree), "expected hash %#x, got %#x", expected, Hash(tree))
+}
+
+func TestHashIntegersByValue(t *testing.T) {
+	// On a 32-bit platform, BigInt would turn these into numbers rather than big integers.
+	values := []int64{0, 1, -1, 1 << 40, -1 << 40, math.MaxInt64, math.MinInt64}
+	for _, v := range values {
+		n := big.NewInt(v)
+		bigInt := Tree{tag: symTreeBigInt, bigInt: n}
+		assert(t.Errorf, Hash(bigInt) == Hash(BigInt(n)), "%d hashes differently as a number and as a big integer", v)
+	}
+}
@@ -0,0 +1,133 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"hash/fnv"
+	"math/big"
+	"sync"
+)
+
+// A Factory builds interned Trees.
+//
+// Whenever it is asked for a Tree equal to one it has built before, a Factory returns the same one again.
+// That way equal subtrees share memory, and Equal compares Trees built by the same Factory in constant time.
+//
+// Interned Trees are ordinary Trees in every other respect.
+// They can be mixed with Trees built in other ways, at the cost of losing the fast comparisons.
+// A Factory keeps every Tree it has built for as long as the Factory itself is in use.
+//
+// A Factory is safe for concurrent use by many goroutines.
+type Factory struct {
+	mu    sync.Mutex
+	trees map[uint64][]Tree
+}
+
+// An internNode identifies an interned Tree.
+type internNode struct {
+	factory *Factory
+	hash    uint64
+}
+
+// NewFactory creates a Factory that hasn't built any Trees yet.
+func NewFactory() *Factory {
+	return &Factory{trees: map[uint64][]Tree{}}
+}
+
+// Sym works like the Sym function, but returns an interned Tree.
+func (f *Factory) Sym(name string) Tree { return f.Intern(Sym(name)) }
+
+// Num works like the Num function, but returns an interned Tree.
+func (f *Factory) Num(n int) Tree { return f.Intern(Num(n)) }
+
+// Float works like the Float function, but returns an interned Tree.
+// As 0 and -0 are equal, whichever of them is built first is returned for both.
+func (f *Factory) Float(x float64) Tree { return f.Intern(Float(x)) }
+
+// BigInt works like the BigInt function, but returns an interned Tree.
+func (f *Factory) BigInt(n *big.Int) Tree { return f.Intern(BigInt(n)) }
+
+// Rat works like the Rat function, but returns an interned Tree.
+func (f *Factory) Rat(r *big.Rat) Tree { return f.Intern(Rat(r)) }
+
+// Str works like the Str function, but returns an interned Tree.
+func (f *Factory) Str(text string) Tree { return f.Intern(Str(text)) }
+
+// Lst works like the Lst function, but returns an interned Tree.
+// The elements get interned as well, if they weren't already.
+func (f *Factory) Lst(elems ...Tree) Tree {
+	interned := make([]Tree, len(elems))
+	for i, elem := range elems {
+		interned[i] = f.Intern(elem)
+	}
+	return f.intern(Lst(interned...))
+}
+
+// DottedLst works like the DottedLst function, but returns an interned Tree.
+// The elements and the tail get interned as well, if they weren't already.
+func (f *Factory) DottedLst(elems []Tree, tail Tree) Tree {
+	interned := make([]Tree, len(elems))
+	for i, elem := range elems {
+		interned[i] = f.Intern(elem)
+	}
+	if tail.tag != symTreeInvalid {
+		tail = f.Intern(tail)
+	}
+	return f.intern(DottedLst(interned, tail))
+}
+
+// Intern returns the interned Tree equal to t.
+// Spans are not kept, as the same interned Tree can stand for text read from many places.
+func (f *Factory) Intern(t Tree) Tree {
+	if t.node != nil && t.node.factory == f {
+		return t
+	}
+	t.span = nil
+	t.node = nil
+	if t.tag == symTreeList {
+		tail, _ := t.list.Tail()
+		return f.DottedLst(t.list.trees(), tail)
+	}
+	return f.intern(t)
+}
+
+// intern finds or stores a Tree whose subtrees are all interned already.
+func (f *Factory) intern(t Tree) Tree {
+	h := f.hash(t)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, candidate := range f.trees[h] {
+		if Equal(candidate, t) {
+			return candidate
+		}
+	}
+	t.node = &internNode{factory: f, hash: h}
+	f.trees[h] = append(f.trees[h], t)
+	return t
+}
+
+// hash works like Hash, but relies on the hashes of interned subtrees, so that it doesn't need to visit all of them.
+func (f *Factory) hash(t Tree) uint64 {
+	if t.tag != symTreeList {
+		return Hash(t)
+	}
+	h := hasher{fnv.New64a()}
+	h.Uint(uint64(t.tag))
+	tail, improper := t.list.Tail()
+	if improper {
+		h.Uint(^uint64(t.list.Len()))
+	} else {
+		h.Uint(uint64(t.list.Len()))
+	}
+	for elem := range t.list.Values() {
+		h.Uint(elem.node.hash)
+	}
+	if improper {
+		h.Uint(tail.node.hash)
+	}
+	return h.Sum64()
+}
@@ -0,0 +1,107 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"math/big"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestFactoryReturnsTheSameNodeForEqualTrees(t *testing.T) {
+	f := NewFactory()
+	build := func() Tree {
+		return f.Lst(f.Sym("+"), f.Num(1), f.Lst(f.Float(2.5), f.Str("x"), f.BigInt(hugeInt()), f.Rat(big.NewRat(1, 3))))
+	}
+
+	a, b := build(), build()
+
+	assert(t.Errorf, a.node != nil && a.node == b.node, "equal trees should share a node")
+	assert(t.Errorf, Equal(a, b), "%v should equal %v", a, b)
+}
+
+func TestFactoryTreesDifferWhenTheirStructureDoes(t *testing.T) {
+	f := NewFactory()
+
+	a := f.Lst(f.Sym("a"), f.Lst(f.Sym("b")))
+	b := f.Lst(f.Lst(f.Sym("a")), f.Sym("b"))
+
+	assert(t.Errorf, !Equal(a, b), "%v should not equal %v", a, b)
+}
+
+func TestFactoryTreesEqualOrdinaryTrees(t *testing.T) {
+	f := NewFactory()
+	tree := Lst(Sym("+"), Num(1), Lst(Str("x")))
+
+	interned := f.Intern(tree)
+
+	assert(t.Errorf, Equal(tree, interned), "%v should equal %v", tree, interned)
+	assert(t.Errorf, Equal(interned, tree), "%v should equal %v", interned, tree)
+	assert(t.Errorf, Equal(f.Intern(tree), interned), "interning twice should give an equal tree")
+}
+
+func TestTreesFromDifferentFactoriesAreComparedStructurally(t *testing.T) {
+	f, g := NewFactory(), NewFactory()
+
+	a, b := f.Lst(f.Sym("x")), g.Lst(g.Sym("x"))
+
+	assert(t.Errorf, Equal(a, b), "%v should equal %v", a, b)
+	assert(t.Errorf, !Equal(a, g.Lst(g.Sym("y"))), "%v should not equal %v", a, g.Lst(g.Sym("y")))
+}
+
+func TestFactorySharesSubtrees(t *testing.T) {
+	f := NewFactory()
+
+	tree := f.Intern(Lst(Lst(Sym("x"), Num(1)), Lst(Sym("x"), Num(1))))
+
+	tree.IfList(func(l List) {
+		assert(t.Errorf, l.At(0).node == l.At(1).node, "equal subtrees should share a node")
+	})
+}
+
+func TestFactoryDropsSpans(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("(a b)"))
+	dec.RecordSpans("")
+	tree, _ := dec.Decode()
+
+	interned := NewFactory().Intern(tree)
+
+	_, hasSpan := interned.Span()
+	assert(t.Errorf, !hasSpan, "an interned tree should have no span")
+}
+
+func TestFactoryIsSafeForConcurrentUse(t *testing.T) {
+	f := NewFactory()
+	const workers = 8
+	results := make([]Tree, workers)
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 100; i++ {
+				results[w] = f.Lst(f.Sym("n"), f.Num(i), f.Lst(f.Num(i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	for w := 1; w < workers; w++ {
+		assert(t.Errorf, results[0].node == results[w].node, "worker %d got a different node", w)
+	}
+}
+
+func TestFactoryInternsImproperLists(t *testing.T) {
+	f := NewFactory()
+
+	a := f.DottedLst([]Tree{f.Sym("a")}, f.Sym("b"))
+	b := f.Intern(DottedLst([]Tree{Sym("a")}, Sym("b")))
+	c := f.Lst(f.Sym("a"), f.Sym("b"))
+
+	assert(t.Errorf, a.node == b.node, "equal improper lists should share a node")
+	assert(t.Errorf, !Equal(a, c), "%v should not equal %v", a, c)
+}
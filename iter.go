@@ -0,0 +1,135 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"iter"
+	"slices"
+)
+
+// All returns an iterator over the indices and elements of the List, in order.
+func (l List) All() iter.Seq2[int, Tree] {
+	return func(yield func(int, Tree) bool) {
+		i := 0
+		l.root.each(func(t Tree) bool {
+			ok := yield(i, t)
+			i++
+			return ok
+		})
+	}
+}
+
+// Values returns an iterator over the elements of the List, in order.
+func (l List) Values() iter.Seq[Tree] {
+	return func(yield func(Tree) bool) {
+		l.root.each(yield)
+	}
+}
+
+// elemsAndTail returns an iterator over the elements of the List, followed by its tail if it has one.
+// The tail comes with the index right after the last element.
+func (l List) elemsAndTail() iter.Seq2[int, Tree] {
+	return func(yield func(int, Tree) bool) {
+		i := 0
+		done := !l.root.each(func(t Tree) bool {
+			ok := yield(i, t)
+			i++
+			return ok
+		})
+		if !done && l.tail != nil {
+			yield(i, *l.tail)
+		}
+	}
+}
+
+// A listCursor steps through the elements of a List in order, followed by its tail.
+// Unlike the iterators, it lets two Lists be walked side by side.
+type listCursor struct {
+	// pending holds the right children of the branches the cursor went left at.
+	pending []*listNode
+	leaf    []Tree
+	tail    *Tree
+}
+
+func (l List) cursor() listCursor {
+	c := listCursor{tail: l.tail}
+	c.descend(l.root)
+	return c
+}
+
+// descend goes down to the leftmost leaf under the node.
+func (c *listCursor) descend(n *listNode) {
+	for n != nil && !n.isLeaf() {
+		c.pending = append(c.pending, n.right)
+		n = n.left
+	}
+	if n != nil {
+		c.leaf = n.leaf
+	}
+}
+
+// next returns the next element, or the tail after the last one.
+// The second result is false when there is nothing left.
+func (c *listCursor) next() (Tree, bool) {
+	for len(c.leaf) == 0 && len(c.pending) > 0 {
+		n := c.pending[len(c.pending)-1]
+		c.pending = c.pending[:len(c.pending)-1]
+		c.descend(n)
+	}
+	if len(c.leaf) > 0 {
+		t := c.leaf[0]
+		c.leaf = c.leaf[1:]
+		return t, true
+	}
+	if c.tail != nil {
+		t := *c.tail
+		c.tail = nil
+		return t, true
+	}
+	return Tree{}, false
+}
+
+// each calls f on the elements under the node, in order, until f returns false.
+// It reports whether f kept returning true.
+func (n *listNode) each(f func(Tree) bool) bool {
+	switch {
+	case n == nil:
+		return true
+	case n.isLeaf():
+		for _, t := range n.leaf {
+			if !f(t) {
+				return false
+			}
+		}
+		return true
+	}
+	return n.left.each(f) && n.right.each(f)
+}
+
+// Subtrees returns an iterator over tree and all of its subtrees, together with their Paths.
+// The subtrees come in pre-order, so each list comes before its elements.
+// The tail of an improper list comes after the elements, with the index right after the last one.
+//
+// Each Path is a fresh slice, which the caller is free to keep or modify.
+func Subtrees(tree Tree) iter.Seq2[Path, Tree] {
+	return func(yield func(Path, Tree) bool) {
+		subtrees(Path{}, tree, yield)
+	}
+}
+
+func subtrees(p Path, tree Tree, yield func(Path, Tree) bool) bool {
+	if !yield(slices.Clone(p), tree) {
+		return false
+	}
+	if tree.tag != symTreeList {
+		return true
+	}
+	for i, elem := range tree.list.elemsAndTail() {
+		if !subtrees(append(p, i), elem, yield) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,95 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestListIterators(t *testing.T) {
+	elems := make([]Tree, 100)
+	for i := range elems {
+		elems[i] = Num(i)
+	}
+	l := Lst(elems...).list
+
+	values := slices.Collect(l.Values())
+	var indices []int
+	for i, elem := range l.All() {
+		indices = append(indices, i)
+		assert(t.Errorf, Equal(elem, Num(i)), "expected element %d to be %d, got %v", i, i, elem)
+	}
+
+	assert(t.Errorf, slices.EqualFunc(values, elems, Equal), "expected %v, got %v", elems, values)
+	assert(t.Errorf, len(indices) == 100 && indices[99] == 99, "expected indices 0 to 99, got %v", indices)
+}
+
+func TestListIteratorsStopEarly(t *testing.T) {
+	l := Lst(Num(0), Num(1), Num(2), Num(3)).list.Append(Num(4))
+
+	var seen []int
+	for i := range l.All() {
+		if i == 2 {
+			break
+		}
+		seen = append(seen, i)
+	}
+	count := 0
+	for range l.Values() {
+		count++
+		if count == 3 {
+			break
+		}
+	}
+
+	assert(t.Errorf, slices.Equal(seen, []int{0, 1}), "expected to see 0 and 1, saw %v", seen)
+	assert(t.Errorf, count == 3, "expected to see 3 values, saw %d", count)
+}
+
+func TestSubtrees(t *testing.T) {
+	tree := Lst(Sym("a"), Lst(Sym("b"), Lst()), Sym("c"))
+
+	var paths []string
+	var trees []Tree
+	for p, subtree := range Subtrees(tree) {
+		paths = append(paths, p.String())
+		trees = append(trees, subtree)
+	}
+
+	expectedPaths := []string{"/", "/0", "/1", "/1/0", "/1/1", "/2"}
+	expectedTrees := []Tree{tree, Sym("a"), Lst(Sym("b"), Lst()), Sym("b"), Lst(), Sym("c")}
+	assert(t.Errorf, slices.Equal(paths, expectedPaths), "expected paths %v, got %v", expectedPaths, paths)
+	assert(t.Errorf, slices.EqualFunc(trees, expectedTrees, Equal), "expected subtrees %v, got %v", expectedTrees, trees)
+}
+
+func TestSubtreesPathsCanBeKept(t *testing.T) {
+	tree := Lst(Lst(Sym("a"), Sym("b")), Lst(Sym("c")))
+
+	var paths []Path
+	for p := range Subtrees(tree) {
+		paths = append(paths, p)
+	}
+
+	for _, p := range paths {
+		_, err := GetAt(tree, p)
+		assert(t.Errorf, err == nil, "the kept path %v changed: %v", p, err)
+	}
+	assert(t.Errorf, paths[len(paths)-1].String() == "/1/0", "expected the last path to be /1/0, got %v", paths[len(paths)-1])
+}
+
+func TestSubtreesStopEarly(t *testing.T) {
+	tree := Lst(Sym("a"), Lst(Sym("b"), Sym("stop"), Sym("c")), Sym("d"))
+
+	var seen []Tree
+	for _, subtree := range Subtrees(tree) {
+		if Equal(subtree, Sym("stop")) {
+			break
+		}
+		seen = append(seen, subtree)
+	}
+
+	assert(t.Errorf, len(seen) == 4, "expected to see 4 subtrees before stopping, saw %v", seen)
+}
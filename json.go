@@ -0,0 +1,356 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MarshalJSON encodes a Tree as JSON, so that UnmarshalJSON can get back an Equal Tree.
+//
+// The mapping is
+//
+//   - an invalid Tree is null
+//   - a symbol is an object like {"symbol": "name"}
+//   - an integer of any size is a JSON number without a fraction or an exponent
+//   - a float is a JSON number with a fraction or an exponent, as in 1.0,
+//     except that NaN and the infinities are {"float": "NaN"}, {"float": "+Inf"} and {"float": "-Inf"}
+//   - a rational is an object like {"rat": "1/3"}
+//   - a string is a JSON string
+//   - a list is an array of its elements
+//   - an improper list is an object like {"list": [1, 2], "tail": 3}
+//
+// JSON strings can't hold bytes that are not valid UTF-8.
+// So strings and symbols with such bytes are objects like {"bytes": "/w=="} and {"symbolBytes": "/w=="} instead,
+// with the bytes encoded in base64.
+//
+// Spans are not kept.
+// See NaturalJSON for a mapping closer to how JSON is usually written.
+func (tree Tree) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	writeJSON(&buf, tree)
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, tree Tree) {
+	switch tree.tag {
+	case symTreeInvalid:
+		buf.WriteString("null")
+	case symTreeSymbol:
+		if !utf8.ValidString(tree.symbol) {
+			writeJSONObject(buf, "symbolBytes", base64.StdEncoding.EncodeToString([]byte(tree.symbol)))
+			return
+		}
+		writeJSONObject(buf, "symbol", tree.symbol)
+	case symTreeNumber:
+		buf.WriteString(strconv.Itoa(tree.number))
+	case symTreeBigInt:
+		buf.WriteString(tree.bigInt.String())
+	case symTreeFloat:
+		if math.IsNaN(tree.float) || math.IsInf(tree.float, 0) {
+			writeJSONObject(buf, "float", strconv.FormatFloat(tree.float, 'g', -1, 64))
+			return
+		}
+		buf.WriteString(formatFloat(tree.float))
+	case symTreeRat:
+		writeJSONObject(buf, "rat", tree.rat.String())
+	case symTreeString:
+		if !utf8.ValidString(tree.symbol) {
+			writeJSONObject(buf, "bytes", base64.StdEncoding.EncodeToString([]byte(tree.symbol)))
+			return
+		}
+		writeJSONString(buf, tree.symbol)
+	case symTreeList:
+		tail, improper := tree.list.Tail()
+		if improper {
+			buf.WriteString(`{"list":`)
+		}
+		buf.WriteByte('[')
+		for i, elem := range tree.list.All() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSON(buf, elem)
+		}
+		buf.WriteByte(']')
+		if improper {
+			buf.WriteString(`,"tail":`)
+			writeJSON(buf, tail)
+			buf.WriteByte('}')
+		}
+	}
+}
+
+func writeJSONObject(buf *bytes.Buffer, key, value string) {
+	buf.WriteByte('{')
+	writeJSONString(buf, key)
+	buf.WriteByte(':')
+	writeJSONString(buf, value)
+	buf.WriteByte('}')
+}
+
+func writeJSONString(buf *bytes.Buffer, text string) {
+	quoted, _ := json.Marshal(text)
+	buf.Write(quoted)
+}
+
+// UnmarshalJSON decodes a Tree from JSON written the way MarshalJSON writes it.
+// Integers get the same representation as they would from BigInt.
+func (tree *Tree) UnmarshalJSON(data []byte) error {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	t, err := fromJSON(v)
+	if err != nil {
+		return err
+	}
+	*tree = t
+	return nil
+}
+
+// decodeJSON decodes any JSON value, keeping the text of numbers.
+func decodeJSON(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func fromJSON(v any) (Tree, error) {
+	switch v := v.(type) {
+	case nil:
+		return Tree{}, nil
+	case json.Number:
+		return jsonNumber(v)
+	case string:
+		return Str(v), nil
+	case []any:
+		return jsonList(v, nil, fromJSON)
+	case map[string]any:
+		return fromJSONObject(v)
+	}
+	return Tree{}, fmt.Errorf("cannot decode %v as a Tree", v)
+}
+
+func fromJSONObject(obj map[string]any) (Tree, error) {
+	text := func(key string) (string, bool) {
+		s, ok := obj[key].(string)
+		return s, ok && len(obj) == 1
+	}
+	if name, ok := text("symbol"); ok {
+		return Sym(name), nil
+	}
+	if encoded, ok := text("symbolBytes"); ok {
+		name, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return Tree{}, fmt.Errorf("invalid symbol bytes %q: %w", encoded, err)
+		}
+		return Sym(string(name)), nil
+	}
+	if encoded, ok := text("bytes"); ok {
+		text, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return Tree{}, fmt.Errorf("invalid string bytes %q: %w", encoded, err)
+		}
+		return Str(string(text)), nil
+	}
+	if f, ok := text("float"); ok {
+		switch f {
+		case "NaN":
+			return Float(math.NaN()), nil
+		case "+Inf":
+			return Float(math.Inf(+1)), nil
+		case "-Inf":
+			return Float(math.Inf(-1)), nil
+		}
+		return Tree{}, fmt.Errorf("invalid float %q: it should be NaN, +Inf or -Inf", f)
+	}
+	if r, ok := text("rat"); ok {
+		rat, ok := new(big.Rat).SetString(r)
+		if !ok || strings.ContainsAny(r, ".eE") {
+			return Tree{}, fmt.Errorf("invalid rational %q", r)
+		}
+		return Rat(rat), nil
+	}
+	elems, hasList := obj["list"].([]any)
+	tail, hasTail := obj["tail"]
+	if hasList && hasTail && tail != nil && len(obj) == 2 {
+		return jsonList(elems, tail, fromJSON)
+	}
+	return Tree{}, fmt.Errorf("cannot decode an object with the keys %s as a Tree", strings.Join(slices.Sorted(maps.Keys(obj)), ", "))
+}
+
+// jsonNumber converts a JSON number to an integer, when it has neither a fraction nor an exponent, or a float otherwise.
+func jsonNumber(n json.Number) (Tree, error) {
+	if !strings.ContainsAny(n.String(), ".eE") {
+		i, ok := new(big.Int).SetString(n.String(), 10)
+		if !ok {
+			return Tree{}, fmt.Errorf("invalid integer %s", n)
+		}
+		return BigInt(i), nil
+	}
+	f, err := strconv.ParseFloat(n.String(), 64)
+	if err != nil {
+		return Tree{}, fmt.Errorf("invalid float %s: %w", n, err)
+	}
+	return Float(f), nil
+}
+
+// jsonList converts the elements and, unless it is nil, the tail of a list.
+func jsonList(elems []any, tail any, convert func(any) (Tree, error)) (Tree, error) {
+	trees := make([]Tree, len(elems))
+	for i, elem := range elems {
+		t, err := convert(elem)
+		if err != nil {
+			return Tree{}, err
+		}
+		trees[i] = t
+	}
+	if tail == nil {
+		return Lst(trees...), nil
+	}
+	t, err := convert(tail)
+	if err != nil {
+		return Tree{}, err
+	}
+	if len(trees) == 0 || t.tag == symTreeInvalid || t.tag == symTreeList {
+		return Tree{}, fmt.Errorf("invalid improper list: it needs elements and a tail that is not a list")
+	}
+	return DottedLst(trees, t), nil
+}
+
+// NaturalJSON wraps a Tree to give it a JSON mapping closer to how JSON is usually written.
+// Unlike the mapping of Tree itself, it loses information.
+//
+// A NaturalJSON encodes
+//
+//   - an invalid Tree and the symbol nil as null
+//   - the symbols true and false as booleans
+//   - other symbols and strings as JSON strings, with bytes that are not valid UTF-8 replaced by U+FFFD
+//   - integers and floats as JSON numbers
+//   - rationals as the JSON numbers closest to them
+//   - lists as arrays of their elements
+//
+// It can't encode improper lists, NaN or the infinities.
+//
+// It decodes
+//
+//   - null as the symbol nil
+//   - booleans as the symbols true and false
+//   - JSON strings as strings
+//   - JSON numbers as integers, when they have neither a fraction nor an exponent, or floats otherwise
+//   - arrays as lists of their elements
+//   - objects as lists of (key value) lists, ordered by key
+//
+// These are the same conventions Marshal and Unmarshal use for nil, bools and maps.
+type NaturalJSON struct {
+	Tree
+}
+
+// MarshalJSON encodes the Tree with the natural mapping.
+func (nj NaturalJSON) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeNaturalJSON(&buf, nj.Tree); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeNaturalJSON(buf *bytes.Buffer, tree Tree) error {
+	switch tree.tag {
+	case symTreeInvalid:
+		buf.WriteString("null")
+	case symTreeSymbol:
+		switch tree.symbol {
+		case "nil":
+			buf.WriteString("null")
+		case "true", "false":
+			buf.WriteString(tree.symbol)
+		default:
+			writeJSONString(buf, tree.symbol)
+		}
+	case symTreeNumber, symTreeBigInt:
+		writeJSON(buf, tree)
+	case symTreeFloat:
+		if math.IsNaN(tree.float) || math.IsInf(tree.float, 0) {
+			return fmt.Errorf("cannot encode %v as a JSON number", tree)
+		}
+		buf.WriteString(strconv.FormatFloat(tree.float, 'g', -1, 64))
+	case symTreeRat:
+		f, _ := tree.rat.Float64()
+		buf.WriteString(strconv.FormatFloat(f, 'g', -1, 64))
+	case symTreeString:
+		writeJSONString(buf, tree.symbol)
+	case symTreeList:
+		if !tree.list.IsProper() {
+			return fmt.Errorf("cannot encode the improper list %v as a JSON array", tree)
+		}
+		buf.WriteByte('[')
+		for i, elem := range tree.list.All() {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeNaturalJSON(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the Tree with the natural mapping.
+func (nj *NaturalJSON) UnmarshalJSON(data []byte) error {
+	v, err := decodeJSON(data)
+	if err != nil {
+		return err
+	}
+	t, err := fromNaturalJSON(v)
+	if err != nil {
+		return err
+	}
+	nj.Tree = t
+	return nil
+}
+
+func fromNaturalJSON(v any) (Tree, error) {
+	switch v := v.(type) {
+	case nil:
+		return nilSymbol, nil
+	case bool:
+		return Sym(boolSymbol(v)), nil
+	case json.Number:
+		return jsonNumber(v)
+	case string:
+		return Str(v), nil
+	case []any:
+		return jsonList(v, nil, fromNaturalJSON)
+	case map[string]any:
+		entries := make([]Tree, 0, len(v))
+		for _, key := range slices.Sorted(maps.Keys(v)) {
+			value, err := fromNaturalJSON(v[key])
+			if err != nil {
+				return Tree{}, err
+			}
+			entries = append(entries, Lst(Str(key), value))
+		}
+		return Lst(entries...), nil
+	}
+	return Tree{}, fmt.Errorf("cannot decode %v as a Tree", v)
+}
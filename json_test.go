@@ -0,0 +1,138 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"encoding/json"
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+func TestTreeJSON(t *testing.T) {
+	cases := map[string]struct {
+		tree Tree
+		json string
+	}{
+		"invalid":       {Tree{}, `null`},
+		"symbol":        {Sym("foo"), `{"symbol":"foo"}`},
+		"number":        {Num(-12), `-12`},
+		"bigInt":        {BigInt(hugeInt()), hugeInt().String()},
+		"float":         {Float(1.5), `1.5`},
+		"wholeFloat":    {Float(3), `3.0`},
+		"negativeZero":  {Float(math.Copysign(0, -1)), `-0.0`},
+		"hugeFloat":     {Float(1e300), `1e+300`},
+		"nan":           {Float(math.NaN()), `{"float":"NaN"}`},
+		"infinity":      {Float(math.Inf(-1)), `{"float":"-Inf"}`},
+		"rat":           {Rat(big.NewRat(-1, 3)), `{"rat":"-1/3"}`},
+		"string":        {Str("a \"b\"\n"), `"a \"b\"\n"`},
+		"list":          {Lst(Sym("+"), Num(1), Lst()), `[{"symbol":"+"},1,[]]`},
+		"stringNotUTF8": {Str("a\xffb"), `{"bytes":"Yf9i"}`},
+		"symbolNotUTF8": {Sym("\xff"), `{"symbolBytes":"/w=="}`},
+		"improperList":  {DottedLst([]Tree{Num(1), Num(2)}, Sym("c")), `{"list":[1,2],"tail":{"symbol":"c"}}`},
+	}
+	for name, c := range cases {
+		data, err := json.Marshal(c.tree)
+		assert(t.Errorf, err == nil && string(data) == c.json, "%s: expected %s, got %s, %v", name, c.json, data, err)
+
+		var back Tree
+		err = json.Unmarshal([]byte(c.json), &back)
+		assert(t.Errorf, err == nil && Equal(back, c.tree), "%s: expected %v back, got %v, %v", name, c.tree, back, err)
+		assert(t.Errorf, back.tag == c.tree.tag, "%s: the shape changed from %v to %v", name, c.tree.tag, back.tag)
+	}
+}
+
+func TestTreeJSONInDocuments(t *testing.T) {
+	type document struct {
+		Name string `json:"name"`
+		Body Tree   `json:"body"`
+	}
+	tree, _ := ReadSexpr(strings.NewReader(`(define (f x) (* x 2.0 "x"))`))
+	data, err := json.Marshal(document{"f", tree})
+	assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+
+	var back document
+	err = json.Unmarshal(data, &back)
+	assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+	assert(t.Errorf, Equal(back.Body, tree), "expected %v, got %v", tree, back.Body)
+}
+
+func TestTreeJSONErrors(t *testing.T) {
+	cases := map[string]string{
+		"boolean":          `true`,
+		"unknownObject":    `{"foo":1}`,
+		"extraKey":         `{"symbol":"a","x":1}`,
+		"symbolNotString":  `{"symbol":1}`,
+		"badFloat":         `{"float":"1.5"}`,
+		"floatOutOfRange":  `1e400`,
+		"badRat":           `{"rat":"0.5"}`,
+		"badBytes":         `{"bytes":"!"}`,
+		"badSymbolBytes":   `{"symbolBytes":"!"}`,
+		"listTail":         `{"list":[1],"tail":[2]}`,
+		"nullTail":         `{"list":[1],"tail":null}`,
+		"emptyDottedList":  `{"list":[],"tail":1}`,
+		"nestedBadElement": `[1,[true]]`,
+	}
+	for name, text := range cases {
+		var tree Tree
+		err := json.Unmarshal([]byte(text), &tree)
+		assert(t.Errorf, err != nil, "%s: expected an error, got %v", name, tree)
+	}
+}
+
+func TestNaturalJSON(t *testing.T) {
+	cases := map[string]struct {
+		tree Tree
+		json string
+	}{
+		"nil":      {Sym("nil"), `null`},
+		"booleans": {Lst(Sym("true"), Sym("false")), `[true,false]`},
+		"string":   {Str("a"), `"a"`},
+		"integers": {Lst(Num(1), BigInt(hugeInt())), `[1,` + hugeInt().String() + `]`},
+		"float":    {Float(2.5), `2.5`},
+		"object":   {Lst(Lst(Str("a"), Num(1)), Lst(Str("b"), Lst())), `[["a",1],["b",[]]]`},
+	}
+	for name, c := range cases {
+		data, err := json.Marshal(NaturalJSON{c.tree})
+		assert(t.Errorf, err == nil && string(data) == c.json, "%s: expected %s, got %s, %v", name, c.json, data, err)
+
+		var back NaturalJSON
+		err = json.Unmarshal([]byte(c.json), &back)
+		assert(t.Errorf, err == nil && Equal(back.Tree, c.tree), "%s: expected %v back, got %v, %v", name, c.tree, back.Tree, err)
+	}
+
+	var back NaturalJSON
+	err := json.Unmarshal([]byte(`{"b":[1.0,null],"a":{"c":true}}`), &back)
+	want := `(("a" (("c" true))) ("b" (1.0 nil)))`
+	got := sexpr{back.Tree}.String()
+	assert(t.Errorf, err == nil && got == want, "expected objects to decode as %s, got %s, %v", want, got, err)
+
+	var m map[string]int
+	err = Unmarshal(back.Tree, &m)
+	assert(t.Errorf, err != nil, "expected an UnmarshalError for the nested values")
+	err = json.Unmarshal([]byte(`{"x":1,"y":2}`), &back)
+	assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+	err = Unmarshal(back.Tree, &m)
+	assert(t.Errorf, err == nil && m["x"] == 1 && m["y"] == 2, "expected a natural JSON object to unmarshal into a map, got %v, %v", m, err)
+
+	lossy := map[string]Tree{
+		"symbol": Sym("foo"),
+		"rat":    Rat(big.NewRat(1, 4)),
+	}
+	for name, tree := range lossy {
+		_, err := json.Marshal(NaturalJSON{tree})
+		assert(t.Errorf, err == nil, "%s: unexpected error: %v", name, err)
+	}
+	unencodable := map[string]Tree{
+		"nan":          Float(math.NaN()),
+		"infinity":     Float(math.Inf(1)),
+		"improperList": Lst(DottedLst([]Tree{Num(1)}, Num(2))),
+	}
+	for name, tree := range unencodable {
+		_, err := json.Marshal(NaturalJSON{tree})
+		assert(t.Errorf, err != nil, "%s: expected an error", name)
+	}
+}
@@ -0,0 +1,150 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// A doc is a document that can be laid out in more than one way.
+// It is an algebra in the style of Wadler's "A prettier printer".
+//
+// A doc is one of
+//
+//   - docText, which is printed as is
+//   - docLine, which is either a space or a line break
+//   - docBreakParent, which is printed as nothing, but forces all the groups around it to break
+//   - docNest, which indents the line breaks inside it
+//   - docAlign, which indents the line breaks inside it to the column where it starts
+//   - docGroup, whose line breaks are either all spaces or all line breaks
+//   - docConcat, which is a sequence of docs
+type doc interface{}
+
+type docText string
+
+// docLine is a line break in a group that doesn't fit on one line, or a space otherwise.
+// A hard line is always a line break, so a group containing one never fits on one line.
+type docLine struct{ hard bool }
+
+type docNest struct {
+	indent int
+	doc    doc
+}
+
+type docAlign struct{ doc doc }
+
+type docBreakParent struct{}
+
+type docGroup struct{ doc doc }
+
+type docConcat []doc
+
+var (
+	softLine doc = docLine{}
+	hardLine doc = docLine{hard: true}
+)
+
+// docCmd is a doc waiting to be laid out, together with how.
+type docCmd struct {
+	indent int
+	flat   bool
+	doc    doc
+}
+
+// layout lays out d so that it fits within width, if at all possible.
+// It tries to keep as many of the outermost groups on one line as it can.
+func layout(d doc, width int) string {
+	var out strings.Builder
+	col := 0
+	stack := []docCmd{{doc: d}}
+	for len(stack) > 0 {
+		cmd := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		switch d := cmd.doc.(type) {
+		case docText:
+			out.WriteString(string(d))
+			if i := strings.LastIndexByte(string(d), '\n'); i >= 0 {
+				col = 0
+				d = d[i+1:]
+			}
+			col += utf8.RuneCountInString(string(d))
+		case docLine:
+			if cmd.flat && !d.hard {
+				out.WriteString(" ")
+				col++
+				continue
+			}
+			out.WriteString("\n")
+			out.WriteString(strings.Repeat(" ", cmd.indent))
+			col = cmd.indent
+		case docNest:
+			stack = append(stack, docCmd{cmd.indent + d.indent, cmd.flat, d.doc})
+		case docAlign:
+			stack = append(stack, docCmd{col, cmd.flat, d.doc})
+		case docGroup:
+			flat := docCmd{cmd.indent, true, d.doc}
+			if !cmd.flat && !fits(width-col, flat, stack) {
+				flat.flat = false
+			}
+			stack = append(stack, flat)
+		case docConcat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, docCmd{cmd.indent, cmd.flat, d[i]})
+			}
+		}
+	}
+	return out.String()
+}
+
+// fits checks whether the text up to the first line break takes up no more than width columns.
+// The text starts with next, followed by rest.
+// The rest is a stack, so the doc to lay out after next comes last.
+func fits(width int, next docCmd, rest []docCmd) bool {
+	stack := []docCmd{next}
+	for width >= 0 {
+		if len(stack) == 0 {
+			if len(rest) == 0 {
+				return true
+			}
+			stack = append(stack, rest[len(rest)-1])
+			rest = rest[:len(rest)-1]
+		}
+		cmd := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		switch d := cmd.doc.(type) {
+		case docText:
+			if cmd.flat && strings.ContainsRune(string(d), '\n') {
+				return false
+			}
+			width -= utf8.RuneCountInString(string(d))
+		case docLine:
+			if !cmd.flat {
+				return true
+			}
+			if d.hard {
+				return false
+			}
+			width--
+		case docBreakParent:
+			if cmd.flat {
+				return false
+			}
+		case docNest:
+			stack = append(stack, docCmd{cmd.indent + d.indent, cmd.flat, d.doc})
+		case docAlign:
+			stack = append(stack, docCmd{cmd.indent, cmd.flat, d.doc})
+		case docGroup:
+			stack = append(stack, docCmd{cmd.indent, cmd.flat, d.doc})
+		case docConcat:
+			for i := len(d) - 1; i >= 0; i-- {
+				stack = append(stack, docCmd{cmd.indent, cmd.flat, d[i]})
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,356 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import "fmt"
+
+// A List is an immutable sequence of Trees.
+//
+// A List can also be improper, with a tail after its elements, as in (a b . c).
+// The tail is never a list, and an improper List has at least one element.
+// Only the Tail method sees the tail.
+// The other methods deal with the elements, leaving the tail as it was,
+// unless they are documented otherwise.
+//
+// The methods that change a List return a new one instead of modifying the receiver.
+// They take time logarithmic in the length of the List, not counting the Trees they add,
+// and the new List shares all the unchanged parts with the old one.
+// The Tree method turns the result back into a Tree, without copying it.
+//
+// Just as for Trees, the == operator doesn't work for Lists.
+type List struct {
+	// The func array makes Lists, and the Trees holding them, impossible to compare with ==.
+	// That would only compare the pointers.
+	_    [0]func()
+	root *listNode
+	tail *Tree
+}
+
+// A listNode is a node of a balanced binary tree, holding the elements of a List in its leaves.
+// The heights of the children of a branch differ by at most one.
+//
+// Empty Lists have no nodes at all, so no leaf is ever empty.
+type listNode struct {
+	size, height int
+	left, right  *listNode
+	leaf         []Tree
+}
+
+// maxLeafSize is the number of elements up to which leaves get merged.
+const maxLeafSize = 32
+
+// listOf creates a List with the given elements.
+// The List takes ownership of the slice.
+func listOf(elems []Tree) List {
+	leaves := make([]*listNode, 0, (len(elems)+maxLeafSize-1)/maxLeafSize)
+	for start := 0; start < len(elems); start += maxLeafSize {
+		end := min(start+maxLeafSize, len(elems))
+		leaves = append(leaves, newLeaf(elems[start:end:end]))
+	}
+	return List{root: buildBalanced(leaves)}
+}
+
+// Tree creates a list Tree out of the List.
+// It takes constant time and shares the List rather than copying it.
+func (l List) Tree() Tree {
+	return Tree{tag: symTreeList, list: l}
+}
+
+func buildBalanced(nodes []*listNode) *listNode {
+	switch len(nodes) {
+	case 0:
+		return nil
+	case 1:
+		return nodes[0]
+	}
+	mid := len(nodes) / 2
+	return newBranch(buildBalanced(nodes[:mid]), buildBalanced(nodes[mid:]))
+}
+
+// DottedLst creates an improper list Tree, with tail after the elements.
+//
+// Just as in Lisp, when tail is a list, its elements follow elems instead, so the result can be proper.
+// When tail is invalid, the result is a proper list of elems.
+// When elems is empty, the result is tail itself.
+func DottedLst(elems []Tree, tail Tree) Tree {
+	if len(elems) == 0 && tail.tag != symTreeInvalid {
+		return tail
+	}
+	return Lst(elems...).list.withTail(tail).Tree()
+}
+
+// withTail puts tail after the elements of the List, replacing any tail it had.
+// The List must not be empty, unless the tail is a list or invalid.
+func (l List) withTail(tail Tree) List {
+	switch tail.tag {
+	case symTreeInvalid:
+		l.tail = nil
+		return l
+	case symTreeList:
+		l.tail = nil
+		return l.Concat(tail.list)
+	}
+	l.tail = &tail
+	return l
+}
+
+// Tail returns the tail of an improper List.
+// The second result is false for a proper List, which has no tail.
+func (l List) Tail() (Tree, bool) {
+	if l.tail == nil {
+		return Tree{}, false
+	}
+	return *l.tail, true
+}
+
+// IsProper reports whether the List has no tail.
+func (l List) IsProper() bool { return l.tail == nil }
+
+// Len returns the number of elements in the List.
+func (l List) Len() int { return l.root.len() }
+
+// At looks up the i-th element of the List.
+// If i is out of bounds, then the returned tree is invalid.
+func (l List) At(i int) Tree {
+	if i < 0 || i >= l.Len() {
+		return Tree{}
+	}
+	n := l.root
+	for n.leaf == nil {
+		if i < n.left.size {
+			n = n.left
+		} else {
+			i -= n.left.size
+			n = n.right
+		}
+	}
+	return n.leaf[i]
+}
+
+// trees copies the elements of the List into a fresh slice.
+func (l List) trees() []Tree {
+	return l.root.appendTo(make([]Tree, 0, l.Len()))
+}
+
+// Append returns a List with the trees added after the elements of the receiver.
+func (l List) Append(trees ...Tree) List {
+	return List{root: join(l.root, Lst(trees...).list.root), tail: l.tail}
+}
+
+// Prepend returns a List with the trees added before the elements of the receiver.
+func (l List) Prepend(trees ...Tree) List {
+	return List{root: join(Lst(trees...).list.root, l.root), tail: l.tail}
+}
+
+// Concat returns a List with the elements of the receiver followed by those of other.
+// When other is proper, the result keeps the tail of the receiver, the same as with Append.
+// Otherwise it has the tail of other, and the tail of the receiver is dropped,
+// since a tail can't be in the middle of a List.
+func (l List) Concat(other List) List {
+	if other.Len() == 0 {
+		return l
+	}
+	tail := other.tail
+	if tail == nil {
+		tail = l.tail
+	}
+	return List{root: join(l.root, other.root), tail: tail}
+}
+
+// Slice returns a List with the elements of the receiver from index i up to, but not including, index j.
+// The result keeps the tail only if it is not empty and reaches the end of the receiver.
+// It panics if the indices are out of range, the same as slicing a Go slice does.
+func (l List) Slice(i, j int) List {
+	l.checkRange(i, j)
+	rest, _ := split(l.root, j)
+	_, slice := split(rest, i)
+	return l.keepTail(List{root: slice}, j == l.Len())
+}
+
+// Set returns a List with the i-th element replaced by t.
+// It panics if i is out of range.
+func (l List) Set(i int, t Tree) List {
+	l.checkIndex(i)
+	return List{root: l.root.set(i, t), tail: l.tail}
+}
+
+// Insert returns a List with the trees inserted before the i-th element.
+// When i is equal to the length of the List, they get appended.
+// It panics if i is out of range.
+func (l List) Insert(i int, trees ...Tree) List {
+	l.checkRange(i, i)
+	before, after := split(l.root, i)
+	return List{root: join(join(before, Lst(trees...).list.root), after), tail: l.tail}
+}
+
+// Delete returns a List without the elements from index i up to, but not including, index j.
+// When no elements are left, the tail goes as well.
+// It panics if the indices are out of range.
+func (l List) Delete(i, j int) List {
+	l.checkRange(i, j)
+	before, rest := split(l.root, i)
+	_, after := split(rest, j-i)
+	return l.keepTail(List{root: join(before, after)}, true)
+}
+
+// keepTail gives the result of an operation on the receiver the same tail,
+// as long as the result is not empty and keep is true.
+func (l List) keepTail(result List, keep bool) List {
+	if keep && result.Len() > 0 {
+		result.tail = l.tail
+	}
+	return result
+}
+
+// extent returns the number of elements in the List, counting the tail as one more.
+func (l List) extent() int {
+	if l.tail != nil {
+		return l.Len() + 1
+	}
+	return l.Len()
+}
+
+// elem works like At, but returns the tail at the index right after the last element.
+func (l List) elem(i int) Tree {
+	if l.tail != nil && i == l.Len() {
+		return *l.tail
+	}
+	return l.At(i)
+}
+
+// setElem works like Set, but replaces the tail at the index right after the last element.
+func (l List) setElem(i int, t Tree) List {
+	if l.tail != nil && i == l.Len() {
+		return l.withTail(t)
+	}
+	return l.Set(i, t)
+}
+
+// deleteElem works like deleting a single element, but drops the tail at the index right after the last element.
+func (l List) deleteElem(i int) List {
+	if l.tail != nil && i == l.Len() {
+		l.tail = nil
+		return l
+	}
+	return l.Delete(i, i+1)
+}
+
+func (l List) checkIndex(i int) {
+	if i < 0 || i >= l.Len() {
+		panic(fmt.Sprintf("symtree: index %d out of range for list of length %d", i, l.Len()))
+	}
+}
+
+func (l List) checkRange(i, j int) {
+	if i < 0 || j < i || j > l.Len() {
+		panic(fmt.Sprintf("symtree: range [%d:%d] out of range for list of length %d", i, j, l.Len()))
+	}
+}
+
+func newLeaf(elems []Tree) *listNode {
+	if len(elems) == 0 {
+		return nil
+	}
+	return &listNode{size: len(elems), leaf: elems}
+}
+
+func newBranch(left, right *listNode) *listNode {
+	return &listNode{
+		size:   left.size + right.size,
+		height: max(left.height, right.height) + 1,
+		left:   left,
+		right:  right,
+	}
+}
+
+func (n *listNode) len() int {
+	if n == nil {
+		return 0
+	}
+	return n.size
+}
+
+func (n *listNode) isLeaf() bool { return n.leaf != nil }
+
+func (n *listNode) appendTo(dst []Tree) []Tree {
+	switch {
+	case n == nil:
+		return dst
+	case n.isLeaf():
+		return append(dst, n.leaf...)
+	}
+	return n.right.appendTo(n.left.appendTo(dst))
+}
+
+func (n *listNode) set(i int, t Tree) *listNode {
+	if n.isLeaf() {
+		leaf := make([]Tree, len(n.leaf))
+		copy(leaf, n.leaf)
+		leaf[i] = t
+		return newLeaf(leaf)
+	}
+	if i < n.left.size {
+		return newBranch(n.left.set(i, t), n.right)
+	}
+	return newBranch(n.left, n.right.set(i-n.left.size, t))
+}
+
+// join builds a node with the elements of a followed by those of b.
+// It takes time proportional to the difference of their heights.
+//
+// Small leaves that end up next to each other get merged,
+// so that adding elements one by one doesn't leave the List with a leaf for each of them.
+func join(a, b *listNode) *listNode {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.isLeaf() && b.isLeaf() && a.size+b.size <= maxLeafSize:
+		leaf := make([]Tree, 0, a.size+b.size)
+		leaf = append(append(leaf, a.leaf...), b.leaf...)
+		return newLeaf(leaf)
+	case a.height > b.height+1 || a.height > b.height && b.isLeaf():
+		return balance(a.left, join(a.right, b))
+	case b.height > a.height+1 || b.height > a.height && a.isLeaf():
+		return balance(join(a, b.left), b.right)
+	}
+	return newBranch(a, b)
+}
+
+// balance builds a branch out of two nodes whose heights differ by at most two,
+// rotating them when they differ by exactly two.
+func balance(left, right *listNode) *listNode {
+	switch {
+	case left.height > right.height+1:
+		if left.left.height >= left.right.height {
+			return newBranch(left.left, newBranch(left.right, right))
+		}
+		return newBranch(newBranch(left.left, left.right.left), newBranch(left.right.right, right))
+	case right.height > left.height+1:
+		if right.right.height >= right.left.height {
+			return newBranch(newBranch(left, right.left), right.right)
+		}
+		return newBranch(newBranch(left, right.left.left), newBranch(right.left.right, right.right))
+	}
+	return newBranch(left, right)
+}
+
+// split divides the elements of a node into those before index i and the rest.
+func split(n *listNode, i int) (*listNode, *listNode) {
+	switch {
+	case n == nil || i <= 0:
+		return nil, n
+	case i >= n.size:
+		return n, nil
+	case n.isLeaf():
+		return newLeaf(n.leaf[:i:i]), newLeaf(n.leaf[i:])
+	case i < n.left.size:
+		before, after := split(n.left, i)
+		return before, join(after, n.right)
+	}
+	before, after := split(n.right, i-n.left.size)
+	return join(n.left, before), after
+}
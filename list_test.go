@@ -0,0 +1,217 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestListOperations(t *testing.T) {
+	l := Lst(Num(0), Num(1), Num(2), Num(3)).list
+	cases := map[string]struct {
+		actual   List
+		expected Tree
+	}{
+		"append":      {l.Append(Num(4), Num(5)), Lst(Num(0), Num(1), Num(2), Num(3), Num(4), Num(5))},
+		"prepend":     {l.Prepend(Num(-1)), Lst(Num(-1), Num(0), Num(1), Num(2), Num(3))},
+		"concat":      {l.Concat(l), Lst(Num(0), Num(1), Num(2), Num(3), Num(0), Num(1), Num(2), Num(3))},
+		"slice":       {l.Slice(1, 3), Lst(Num(1), Num(2))},
+		"emptySlice":  {l.Slice(2, 2), Lst()},
+		"set":         {l.Set(2, Sym("x")), Lst(Num(0), Num(1), Sym("x"), Num(3))},
+		"insert":      {l.Insert(1, Sym("x"), Sym("y")), Lst(Num(0), Sym("x"), Sym("y"), Num(1), Num(2), Num(3))},
+		"insertAtEnd": {l.Insert(4, Sym("x")), Lst(Num(0), Num(1), Num(2), Num(3), Sym("x"))},
+		"delete":      {l.Delete(1, 3), Lst(Num(0), Num(3))},
+		"deleteAll":   {l.Delete(0, 4), Lst()},
+	}
+	for name, c := range cases {
+		actual := c.actual.Tree()
+		assert(t.Errorf, Equal(actual, c.expected), "%s: expected %v, got %v", name, c.expected, actual)
+	}
+	assert(t.Errorf, Equal(l.Tree(), Lst(Num(0), Num(1), Num(2), Num(3))), "the original list changed: %v", l)
+}
+
+func TestListTreeSharesTheList(t *testing.T) {
+	l := Lst(Num(0), Num(1)).list.Append(Num(2))
+
+	tree := l.Tree()
+
+	var got List
+	tree.IfList(func(l List) { got = l })
+	assert(t.Errorf, got.root == l.root, "expected the Tree to share the nodes of %v", l)
+	assert(t.Errorf, Equal(tree, Lst(Num(0), Num(1), Num(2))), "expected (0 1 2), got %v", tree)
+}
+
+func TestListsOfDifferentShapesCompareByTheirElements(t *testing.T) {
+	elems := make([]Tree, 1000)
+	var appended List
+	for i := range elems {
+		elems[i] = Num(i)
+		appended = appended.Append(Num(i))
+	}
+	built := Lst(elems...)
+	changed := built.list.Set(999, Num(-1)).Tree()
+
+	assert(t.Errorf, Equal(appended.Tree(), built), "lists with the same elements should be equal")
+	assert(t.Errorf, Compare(appended.Tree(), built) == 0, "lists with the same elements should compare as equal")
+	assert(t.Errorf, !Equal(changed, built), "lists with different last elements should not be equal")
+	assert(t.Errorf, Compare(changed, built) < 0, "expected the list with a smaller last element to come first")
+	assert(t.Errorf, appended.IsSorted() && !changed.list.IsSorted(), "only the list with the changed last element should be unsorted")
+
+	withTail := DottedLst(elems, Sym("t"))
+	assert(t.Errorf, Equal(appended.withTail(Sym("t")).Tree(), withTail), "improper lists with the same elements and tail should be equal")
+	assert(t.Errorf, !Equal(appended.withTail(Sym("u")).Tree(), withTail), "improper lists with different tails should not be equal")
+}
+
+func TestListOperationsPanicWhenOutOfRange(t *testing.T) {
+	l := Lst(Num(0), Num(1)).list
+	cases := map[string]func(){
+		"setNegative":    func() { l.Set(-1, Num(0)) },
+		"setPastEnd":     func() { l.Set(2, Num(0)) },
+		"insertPastEnd":  func() { l.Insert(3, Num(0)) },
+		"sliceReversed":  func() { l.Slice(2, 1) },
+		"slicePastEnd":   func() { l.Slice(0, 3) },
+		"deleteNegative": func() { l.Delete(-1, 1) },
+	}
+	for name, f := range cases {
+		assert(t.Errorf, panics(f), "%s: expected a panic", name)
+	}
+}
+
+func TestListOperationsAgreeWithSlices(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	var (
+		l     List
+		model []Tree
+	)
+	for step := 0; step < 5000; step++ {
+		n := len(model)
+		i := rnd.Intn(n + 1)
+		j := i + rnd.Intn(n-i+1)
+		elems := make([]Tree, rnd.Intn(40))
+		for k := range elems {
+			elems[k] = Num(step*100 + k)
+		}
+
+		switch op := rnd.Intn(6); {
+		case op == 0:
+			l, model = l.Append(elems...), append(model, elems...)
+		case op == 1:
+			l, model = l.Prepend(elems...), append(slices.Clone(elems), model...)
+		case op == 2:
+			l, model = l.Insert(i, elems...), slices.Insert(slices.Clone(model), i, elems...)
+		case op == 3 && n > 0 && i < n:
+			l, model = l.Set(i, Sym("x")), slices.Clone(model)
+			model[i] = Sym("x")
+		case op == 4 && n > 2000:
+			l, model = l.Delete(i, j), slices.Delete(slices.Clone(model), i, j)
+		case op == 5 && n > 2000:
+			l, model = l.Slice(i, j), slices.Clone(model[i:j])
+		}
+
+		if !slices.EqualFunc(l.trees(), model, Equal) {
+			t.Fatalf("step %d: the list and the slice differ", step)
+		}
+		checkListNode(t, l.root)
+	}
+}
+
+func TestListLookupTakesLogarithmicTime(t *testing.T) {
+	var l List
+	for i := 0; i < 100000; i++ {
+		l = l.Append(Num(i))
+	}
+
+	assert(t.Errorf, l.root.height <= 20, "the list of %d elements has height %d", l.Len(), l.root.height)
+	for i := 0; i < l.Len(); i += 997 {
+		assert(t.Errorf, Equal(l.At(i), Num(i)), "expected element %d to be %d, got %v", i, i, l.At(i))
+	}
+}
+
+// checkListNode checks that the node is balanced and that its size and height are right.
+func checkListNode(t *testing.T, n *listNode) {
+	t.Helper()
+	if n == nil || n.isLeaf() {
+		return
+	}
+	checkListNode(t, n.left)
+	checkListNode(t, n.right)
+	if n.left == nil || n.right == nil {
+		t.Fatalf("a branch is missing a child")
+	}
+	if n.size != n.left.size+n.right.size || n.height != max(n.left.height, n.right.height)+1 {
+		t.Fatalf("a branch has the wrong size or height")
+	}
+	if diff := n.left.height - n.right.height; diff < -1 || diff > 1 {
+		t.Fatalf("a branch is unbalanced: its children have heights %d and %d", n.left.height, n.right.height)
+	}
+}
+
+func panics(f func()) (panicked bool) {
+	defer func() { panicked = recover() != nil }()
+	f()
+	return false
+}
+
+func TestDottedLst(t *testing.T) {
+	a, b, c := Sym("a"), Sym("b"), Sym("c")
+	cases := map[string]struct {
+		actual   Tree
+		expected string
+	}{
+		"pair":        {DottedLst([]Tree{a}, b), "(a . b)"},
+		"listTail":    {DottedLst([]Tree{a}, Lst(b, c)), "(a b c)"},
+		"dottedTail":  {DottedLst([]Tree{a}, DottedLst([]Tree{b}, c)), "(a b . c)"},
+		"emptyTail":   {DottedLst([]Tree{a}, Lst()), "(a)"},
+		"invalidTail": {DottedLst([]Tree{a, b}, Tree{}), "(a b)"},
+		"noElements":  {DottedLst(nil, c), "c"},
+	}
+	for name, c := range cases {
+		actual := sexpr{c.actual}.String()
+		assert(t.Errorf, actual == c.expected, "%s: expected %s, got %s", name, c.expected, actual)
+	}
+}
+
+func TestListTail(t *testing.T) {
+	DottedLst([]Tree{Sym("a")}, Num(1)).IfList(func(l List) {
+		tail, ok := l.Tail()
+		assert(t.Errorf, ok && Equal(tail, Num(1)), "expected the tail 1, got %v", tail)
+		assert(t.Errorf, !l.IsProper(), "%v should be improper", l)
+		assert(t.Errorf, l.Len() == 1, "expected a single element, got %d", l.Len())
+	})
+	Lst(Sym("a")).IfList(func(l List) {
+		_, ok := l.Tail()
+		assert(t.Errorf, !ok, "a proper list should have no tail")
+		assert(t.Errorf, l.IsProper(), "%v should be proper", l)
+	})
+}
+
+func TestListOperationsOnImproperLists(t *testing.T) {
+	l := DottedLst([]Tree{Num(0), Num(1), Num(2)}, Sym("t")).list
+	cases := map[string]struct {
+		actual   List
+		expected string
+	}{
+		"append":      {l.Append(Num(3)), "(0 1 2 3 . t)"},
+		"prepend":     {l.Prepend(Num(-1)), "(-1 0 1 2 . t)"},
+		"set":         {l.Set(0, Sym("x")), "(x 1 2 . t)"},
+		"insert":      {l.Insert(3, Num(3)), "(0 1 2 3 . t)"},
+		"delete":      {l.Delete(0, 2), "(2 . t)"},
+		"deleteAll":   {l.Delete(0, 3), "()"},
+		"sliceToEnd":  {l.Slice(1, 3), "(1 2 . t)"},
+		"sliceInside": {l.Slice(0, 2), "(0 1)"},
+		"sliceEmpty":  {l.Slice(3, 3), "()"},
+		"sorted":      {DottedLst([]Tree{Num(2), Num(1)}, Sym("t")).list.Sorted(), "(1 2 . t)"},
+		"concatOnto":  {Lst(Num(-1)).list.Concat(l), "(-1 0 1 2 . t)"},
+		"concatEmpty": {l.Concat(List{}), "(0 1 2 . t)"},
+		"concatAfter": {l.Concat(Lst(Num(3)).list), "(0 1 2 3 . t)"},
+		"concatBoth":  {l.Concat(DottedLst([]Tree{Num(3)}, Sym("u")).list), "(0 1 2 3 . u)"},
+	}
+	for name, c := range cases {
+		actual := sexpr{c.actual.Tree()}.String()
+		assert(t.Errorf, actual == c.expected, "%s: expected %s, got %s", name, c.expected, actual)
+	}
+}
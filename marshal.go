@@ -0,0 +1,528 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"math/big"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// A TreeMarshaler can convert itself to a Tree.
+type TreeMarshaler interface {
+	MarshalTree() (Tree, error)
+}
+
+// A TreeUnmarshaler can set itself from a Tree.
+type TreeUnmarshaler interface {
+	UnmarshalTree(Tree) error
+}
+
+// Marshal converts a Go value to a Tree.
+//
+// Values become Trees depending on their type:
+//
+//   - a Tree stays as it is
+//   - a TreeMarshaler becomes whatever its MarshalTree method returns
+//   - a bool becomes the symbol true or false
+//   - an integer becomes a number, which is a big integer when it doesn't fit an int
+//   - a float becomes a floating-point number
+//   - a big.Int or big.Rat becomes the same number
+//   - a string becomes a string
+//   - a slice or an array becomes a list of its elements
+//   - a map becomes a list of (key value) lists, ordered by the keys as Compare orders them
+//   - a struct becomes a list of its fields in keyword style, as in (:Name "x" :Size 3)
+//   - a pointer or an interface becomes whatever it points to, or the symbol nil when it is nil
+//
+// A struct field's keyword is its name with a colon in front.
+// A tag like `symtree:"name"` on the field gives it a different name instead,
+// and `symtree:"-"` leaves the field out.
+// With the omitempty option, as in `symtree:"name,omitempty"`, the field is left out
+// when it is false, zero, nil, empty or an invalid Tree.
+// Unexported fields are always left out, and the fields of embedded structs are treated as if they were the outer struct's own.
+//
+// Marshal fails with an UnsupportedType error for channels, functions and complex numbers.
+func Marshal(v any) (Tree, error) {
+	return marshal(reflect.ValueOf(v))
+}
+
+var (
+	treeType            = reflect.TypeFor[Tree]()
+	bigIntType          = reflect.TypeFor[big.Int]()
+	bigRatType          = reflect.TypeFor[big.Rat]()
+	treeMarshalerType   = reflect.TypeFor[TreeMarshaler]()
+	treeUnmarshalerType = reflect.TypeFor[TreeUnmarshaler]()
+)
+
+var nilSymbol = Sym("nil")
+
+func marshal(v reflect.Value) (Tree, error) {
+	if !v.IsValid() {
+		return nilSymbol, nil
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(treeMarshalerType) {
+		v = v.Addr()
+	}
+	if v.Type().Implements(treeMarshalerType) {
+		if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+			return nilSymbol, nil
+		}
+		return v.Interface().(TreeMarshaler).MarshalTree()
+	}
+
+	switch v.Type() {
+	case treeType:
+		return v.Interface().(Tree), nil
+	case bigIntType:
+		n := v.Interface().(big.Int)
+		return BigInt(&n), nil
+	case bigRatType:
+		r := v.Interface().(big.Rat)
+		return Rat(&r), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return Sym(boolSymbol(v.Bool())), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return BigInt(big.NewInt(v.Int())), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return BigInt(new(big.Int).SetUint64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return Float(v.Float()), nil
+	case reflect.String:
+		return Str(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		return marshalElems(v)
+	case reflect.Map:
+		return marshalMap(v)
+	case reflect.Struct:
+		return marshalStruct(v)
+	case reflect.Pointer, reflect.Interface:
+		if v.IsNil() {
+			return nilSymbol, nil
+		}
+		return marshal(v.Elem())
+	}
+	return Tree{}, unsupportedType{typ: v.Type()}
+}
+
+func boolSymbol(b bool) string {
+	if b {
+		return "true"
+	}
+	return "false"
+}
+
+func marshalElems(v reflect.Value) (Tree, error) {
+	elems := make([]Tree, v.Len())
+	for i := range elems {
+		elem, err := marshal(v.Index(i))
+		if err != nil {
+			return Tree{}, err
+		}
+		elems[i] = elem
+	}
+	return Lst(elems...), nil
+}
+
+func marshalMap(v reflect.Value) (Tree, error) {
+	entries := make([]Tree, 0, v.Len())
+	for iter := v.MapRange(); iter.Next(); {
+		key, err := marshal(iter.Key())
+		if err != nil {
+			return Tree{}, err
+		}
+		value, err := marshal(iter.Value())
+		if err != nil {
+			return Tree{}, err
+		}
+		entries = append(entries, Lst(key, value))
+	}
+	slices.SortFunc(entries, Compare)
+	return Lst(entries...), nil
+}
+
+func marshalStruct(v reflect.Value) (Tree, error) {
+	var elems []Tree
+	for _, f := range structFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index, false)
+		if !ok || f.omitEmpty && isEmptyValue(fv) {
+			continue
+		}
+		value, err := marshal(fv)
+		if err != nil {
+			return Tree{}, err
+		}
+		elems = append(elems, Sym(":"+f.name), value)
+	}
+	return Lst(elems...), nil
+}
+
+// isEmptyValue tells whether a value gets left out by the omitempty option.
+func isEmptyValue(v reflect.Value) bool {
+	if v.Type() == treeType {
+		return v.Interface().(Tree).tag == symTreeInvalid
+	}
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// A structField is a field of a struct that gets marshaled, possibly from within an embedded struct.
+type structField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+// structFields lists the fields of a struct type that get marshaled, in order.
+// When fields of embedded structs have the same name, the least deeply nested one wins.
+func structFields(t reflect.Type) []structField {
+	var fields []structField
+	depths := map[string]int{}
+	var collect func(t reflect.Type, index []int)
+	collect = func(t reflect.Type, index []int) {
+		for i := 0; i < t.NumField(); i++ {
+			sf := t.Field(i)
+			tag := sf.Tag.Get("symtree")
+			if tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			fieldIndex := append(slices.Clone(index), i)
+
+			ft := sf.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if sf.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+				collect(ft, fieldIndex)
+				continue
+			}
+			if !sf.IsExported() {
+				continue
+			}
+			if name == "" {
+				name = sf.Name
+			}
+			if depth, seen := depths[name]; seen && depth <= len(index) {
+				continue
+			}
+			depths[name] = len(index)
+			fields = slices.DeleteFunc(fields, func(f structField) bool { return f.name == name })
+			fields = append(fields, structField{name: name, index: fieldIndex, omitEmpty: opts == "omitempty"})
+		}
+	}
+	collect(t, nil)
+	slices.SortStableFunc(fields, func(a, b structField) int { return slices.Compare(a.index, b.index) })
+	return fields
+}
+
+// fieldByIndex finds a possibly nested field.
+// When alloc is set, it allocates the nil pointers to embedded structs on the way.
+// Otherwise, it fails on them.
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc || !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// Unmarshal stores the Go value a Tree stands for in the value v points to.
+// It reverses what Marshal does, setting struct fields, slice elements and map entries as it goes.
+//
+// A Tree can be stored in a value of type Tree or in an empty interface as it is.
+// A TreeUnmarshaler gets the Tree passed to its UnmarshalTree method.
+// Integers are checked to fit the type they are stored in.
+// Floats can be set from any kind of number, even when that loses precision.
+// A nil symbol sets pointers, interfaces, slices and maps to nil.
+//
+// The keywords of struct fields are matched to names exactly or, failing that, ignoring case.
+// Keywords that don't match any field are ignored.
+// Struct fields and map entries missing from the Tree are left as they were.
+//
+// When a subtree can't be stored, Unmarshal fails with an UnmarshalError telling where it is.
+// It might have set some of the values before failing.
+func Unmarshal(tree Tree, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() {
+		return unmarshalError{tree: tree, typ: reflect.TypeOf(v), path: Path{}, problem: "the target must be a non-nil pointer"}
+	}
+	return unmarshal(tree, rv.Elem(), Path{})
+}
+
+func unmarshal(tree Tree, v reflect.Value, p Path) error {
+	fail := func(problem string) error {
+		return unmarshalError{tree: tree, typ: v.Type(), path: slices.Clone(p), problem: problem}
+	}
+
+	if v.Kind() != reflect.Pointer && v.CanAddr() && reflect.PointerTo(v.Type()).Implements(treeUnmarshalerType) {
+		if err := v.Addr().Interface().(TreeUnmarshaler).UnmarshalTree(tree); err != nil {
+			return unmarshalError{tree: tree, typ: v.Type(), path: slices.Clone(p), cause: err}
+		}
+		return nil
+	}
+
+	switch v.Type() {
+	case treeType:
+		v.Set(reflect.ValueOf(tree))
+		return nil
+	case bigIntType:
+		return unmarshalBigInt(tree, v, fail)
+	case bigRatType:
+		return unmarshalBigRat(tree, v, fail)
+	}
+
+	isNil := Equal(tree, nilSymbol)
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface, reflect.Slice, reflect.Map:
+		if isNil {
+			v.SetZero()
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return unmarshal(tree, v.Elem(), p)
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			return fail("only empty interfaces can be set")
+		}
+		v.Set(reflect.ValueOf(tree))
+		return nil
+	case reflect.Bool:
+		return unmarshalBool(tree, v, fail)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return unmarshalInt(tree, v, fail)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return unmarshalUint(tree, v, fail)
+	case reflect.Float32, reflect.Float64:
+		return unmarshalFloat(tree, v, fail)
+	case reflect.String:
+		ok := false
+		tree.IfString(func(text string) { v.SetString(text); ok = true })
+		if !ok {
+			return fail("")
+		}
+		return nil
+	case reflect.Slice, reflect.Array:
+		return unmarshalElems(tree, v, p, fail)
+	case reflect.Map:
+		return unmarshalMap(tree, v, p, fail)
+	case reflect.Struct:
+		return unmarshalStruct(tree, v, p, fail)
+	}
+	return fail("the type is not supported")
+}
+
+func unmarshalBool(tree Tree, v reflect.Value, fail func(string) error) error {
+	switch {
+	case Equal(tree, Sym("true")):
+		v.SetBool(true)
+	case Equal(tree, Sym("false")):
+		v.SetBool(false)
+	default:
+		return fail("")
+	}
+	return nil
+}
+
+func unmarshalInt(tree Tree, v reflect.Value, fail func(string) error) error {
+	n, ok := integerValue(tree)
+	if !ok {
+		return fail("")
+	}
+	if !n.IsInt64() || v.OverflowInt(n.Int64()) {
+		return fail("the number is out of range")
+	}
+	v.SetInt(n.Int64())
+	return nil
+}
+
+func unmarshalUint(tree Tree, v reflect.Value, fail func(string) error) error {
+	n, ok := integerValue(tree)
+	if !ok {
+		return fail("")
+	}
+	if !n.IsUint64() || v.OverflowUint(n.Uint64()) {
+		return fail("the number is out of range")
+	}
+	v.SetUint(n.Uint64())
+	return nil
+}
+
+// integerValue returns the value of an integer Tree, whatever its size.
+func integerValue(tree Tree) (*big.Int, bool) {
+	switch tree.tag {
+	case symTreeNumber:
+		return big.NewInt(int64(tree.number)), true
+	case symTreeBigInt:
+		return tree.bigInt, true
+	}
+	return nil, false
+}
+
+func unmarshalFloat(tree Tree, v reflect.Value, fail func(string) error) error {
+	var f float64
+	switch tree.tag {
+	case symTreeFloat:
+		f = tree.float
+	case symTreeNumber:
+		f = float64(tree.number)
+	case symTreeBigInt:
+		f, _ = new(big.Float).SetInt(tree.bigInt).Float64()
+	case symTreeRat:
+		f, _ = tree.rat.Float64()
+	default:
+		return fail("")
+	}
+	v.SetFloat(f)
+	return nil
+}
+
+func unmarshalBigInt(tree Tree, v reflect.Value, fail func(string) error) error {
+	n, ok := integerValue(tree)
+	if !ok || !v.CanAddr() {
+		return fail("")
+	}
+	v.Addr().Interface().(*big.Int).Set(n)
+	return nil
+}
+
+func unmarshalBigRat(tree Tree, v reflect.Value, fail func(string) error) error {
+	if !v.CanAddr() {
+		return fail("")
+	}
+	r := v.Addr().Interface().(*big.Rat)
+	if tree.tag == symTreeRat {
+		r.Set(tree.rat)
+		return nil
+	}
+	n, ok := integerValue(tree)
+	if !ok {
+		return fail("")
+	}
+	r.SetInt(n)
+	return nil
+}
+
+// properList returns the elements of a proper list Tree.
+func properList(tree Tree) (List, bool) {
+	if tree.tag != symTreeList || !tree.list.IsProper() {
+		return List{}, false
+	}
+	return tree.list, true
+}
+
+func unmarshalElems(tree Tree, v reflect.Value, p Path, fail func(string) error) error {
+	l, ok := properList(tree)
+	if !ok {
+		return fail("")
+	}
+	if v.Kind() == reflect.Slice {
+		v.Set(reflect.MakeSlice(v.Type(), l.Len(), l.Len()))
+	} else if l.Len() > v.Len() {
+		return fail("the list is too long")
+	}
+	for i, elem := range l.All() {
+		if err := unmarshal(elem, v.Index(i), append(p, i)); err != nil {
+			return err
+		}
+	}
+	for i := l.Len(); i < v.Len(); i++ {
+		v.Index(i).SetZero()
+	}
+	return nil
+}
+
+func unmarshalMap(tree Tree, v reflect.Value, p Path, fail func(string) error) error {
+	l, ok := properList(tree)
+	if !ok {
+		return fail("")
+	}
+	if v.IsNil() {
+		v.Set(reflect.MakeMapWithSize(v.Type(), l.Len()))
+	}
+	for i, entry := range l.All() {
+		pair, ok := properList(entry)
+		if !ok || pair.Len() != 2 {
+			return unmarshalError{tree: entry, typ: v.Type(), path: slices.Clone(append(p, i)), problem: "a map entry must be a (key value) list"}
+		}
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := unmarshal(pair.At(0), key, append(p, i, 0)); err != nil {
+			return err
+		}
+		value := reflect.New(v.Type().Elem()).Elem()
+		if err := unmarshal(pair.At(1), value, append(p, i, 1)); err != nil {
+			return err
+		}
+		v.SetMapIndex(key, value)
+	}
+	return nil
+}
+
+func unmarshalStruct(tree Tree, v reflect.Value, p Path, fail func(string) error) error {
+	l, ok := properList(tree)
+	if !ok || l.Len()%2 != 0 {
+		return fail("a struct must be a list of keywords and values")
+	}
+	fields := structFields(v.Type())
+	for i := 0; i < l.Len(); i += 2 {
+		keyword, isKeyword := "", false
+		l.At(i).IfSymbol(func(name string) { keyword, isKeyword = strings.CutPrefix(name, ":") })
+		if !isKeyword {
+			return unmarshalError{tree: l.At(i), typ: v.Type(), path: slices.Clone(append(p, i)), problem: "expected a keyword"}
+		}
+		f, ok := findField(fields, keyword)
+		if !ok {
+			continue
+		}
+		fv, ok := fieldByIndex(v, f.index, true)
+		if !ok {
+			return unmarshalError{tree: l.At(i + 1), typ: v.Type(), path: slices.Clone(append(p, i+1)), problem: "the field is in an unexported embedded struct"}
+		}
+		if err := unmarshal(l.At(i+1), fv, append(p, i+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func findField(fields []structField, name string) (structField, bool) {
+	for _, f := range fields {
+		if f.name == name {
+			return f, true
+		}
+	}
+	for _, f := range fields {
+		if strings.EqualFold(f.name, name) {
+			return f, true
+		}
+	}
+	return structField{}, false
+}
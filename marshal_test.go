@@ -0,0 +1,193 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type marshalBase struct {
+	ID int `symtree:"id"`
+}
+
+type marshalPoint struct {
+	X, Y int
+}
+
+type marshalRecord struct {
+	marshalBase
+	Name    string           `symtree:"name"`
+	Tags    []string         `symtree:"tags,omitempty"`
+	Sizes   map[string]uint8 `symtree:"sizes,omitempty"`
+	Origin  *marshalPoint    `symtree:"origin"`
+	Ratio   float64          `symtree:"ratio,omitempty"`
+	Skipped int              `symtree:"-"`
+	Extra   Tree             `symtree:"extra,omitempty"`
+	hidden  int
+}
+
+// celsius marshals itself as a (celsius n) list.
+type celsius int
+
+func (c celsius) MarshalTree() (Tree, error) {
+	return Lst(Sym("celsius"), Num(int(c))), nil
+}
+
+func (c *celsius) UnmarshalTree(t Tree) error {
+	ok := false
+	t.IfList(func(l List) {
+		if l.Len() == 2 && Equal(l.At(0), Sym("celsius")) {
+			l.At(1).IfNumber(func(n int) { *c, ok = celsius(n), true })
+		}
+	})
+	if !ok {
+		return errors.New("expected a (celsius n) list")
+	}
+	return nil
+}
+
+func TestMarshal(t *testing.T) {
+	var nilPoint *marshalPoint
+	cases := map[string]struct {
+		value any
+		want  string
+	}{
+		"int":        {42, "42"},
+		"hugeUint":   {uint64(math.MaxUint64), "18446744073709551615"},
+		"bool":       {true, "true"},
+		"float":      {1.5, "1.5"},
+		"string":     {"hi", `"hi"`},
+		"bigInt":     {hugeInt(), hugeInt().String()},
+		"bigRat":     {big.NewRat(1, 3), "1/3"},
+		"slice":      {[]int{1, 2, 3}, "(1 2 3)"},
+		"nilSlice":   {[]int(nil), "()"},
+		"array":      {[2]string{"a", "b"}, `("a" "b")`},
+		"map":        {map[string]int{"b": 2, "a": 1}, `(("a" 1) ("b" 2))`},
+		"nil":        {nil, "nil"},
+		"nilPointer": {nilPoint, "nil"},
+		"pointer":    {&marshalPoint{1, 2}, "(:X 1 :Y 2)"},
+		"tree":       {Lst(Sym("a")), "(a)"},
+		"marshaler":  {celsius(20), "(celsius 20)"},
+		"marshalers": {[]celsius{1, 2}, "((celsius 1) (celsius 2))"},
+		"struct": {
+			marshalRecord{marshalBase: marshalBase{7}, Name: "x", Skipped: 3, hidden: 4},
+			`(:id 7 :name "x" :origin nil)`,
+		},
+		"structOmitEmptyFilled": {
+			marshalRecord{Tags: []string{"t"}, Sizes: map[string]uint8{"s": 1}, Ratio: 0.5, Extra: Sym("e")},
+			`(:id 0 :name "" :tags ("t") :sizes (("s" 1)) :origin nil :ratio 0.5 :extra e)`,
+		},
+	}
+	for name, c := range cases {
+		tree, err := Marshal(c.value)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", name, err)
+			continue
+		}
+		got := sexpr{tree}.String()
+		assert(t.Errorf, got == c.want, "%s: expected %s, got %s", name, c.want, got)
+	}
+}
+
+func TestMarshalUnsupportedType(t *testing.T) {
+	_, err := Marshal(struct{ C chan int }{})
+	var ut UnsupportedType
+	assert(t.Fatalf, errors.As(err, &ut), "expected an UnsupportedType error, got %v", err)
+	assert(t.Errorf, ut.Type() == reflect.TypeFor[chan int](), "expected the channel type, got %v", ut.Type())
+}
+
+func TestUnmarshalRoundTrip(t *testing.T) {
+	cases := map[string]any{
+		"int":      -5,
+		"uint8":    uint8(255),
+		"bool":     false,
+		"float":    2.25,
+		"string":   "text",
+		"slice":    []string{"a", "b"},
+		"array":    [3]int{1, 2, 3},
+		"map":      map[int]bool{1: true, 2: false},
+		"pointer":  &marshalPoint{3, 4},
+		"tree":     Lst(Sym("x"), Num(1)),
+		"celsius":  celsius(-3),
+		"bigInt":   *hugeInt(),
+		"bigRat":   *big.NewRat(2, 7),
+		"nested":   map[string][]marshalPoint{"line": {{0, 0}, {1, 1}}},
+		"embedded": marshalRecord{marshalBase: marshalBase{9}, Name: "n", Tags: []string{"a"}, Origin: &marshalPoint{1, 1}},
+	}
+	for name, value := range cases {
+		tree, err := Marshal(value)
+		if err != nil {
+			t.Errorf("%s: unexpected marshal error: %s", name, err)
+			continue
+		}
+		target := reflect.New(reflect.TypeOf(value))
+		if err := Unmarshal(tree, target.Interface()); err != nil {
+			t.Errorf("%s: unexpected unmarshal error: %s", name, err)
+			continue
+		}
+		again, err := Marshal(target.Elem().Interface())
+		assert(t.Errorf, err == nil && Equal(again, tree), "%s: expected %v, got %v", name, tree, again)
+	}
+}
+
+func TestUnmarshalStruct(t *testing.T) {
+	tree, _ := ReadSexpr(strings.NewReader(`(:ID 1 :NAME "n" :unknown (a b) :origin (:x 5))`))
+	r := marshalRecord{Skipped: 8, Origin: &marshalPoint{Y: 6}}
+	err := Unmarshal(tree, &r)
+	assert(t.Fatalf, err == nil, "unexpected error: %v", err)
+	assert(t.Errorf, r.ID == 1, "expected id 1, got %d", r.ID)
+	assert(t.Errorf, r.Name == "n", "expected name n, got %q", r.Name)
+	assert(t.Errorf, r.Skipped == 8, "a skipped field was changed to %d", r.Skipped)
+	assert(t.Errorf, *r.Origin == marshalPoint{5, 6}, "expected origin {5 6}, got %v", *r.Origin)
+
+	var v any
+	err = Unmarshal(tree, &v)
+	assert(t.Errorf, err == nil && Equal(v.(Tree), tree), "an empty interface should get the Tree itself, got %v, %v", v, err)
+
+	var p *marshalPoint = &marshalPoint{}
+	err = Unmarshal(Sym("nil"), &p)
+	assert(t.Errorf, err == nil && p == nil, "nil should clear a pointer, got %v, %v", p, err)
+}
+
+func TestUnmarshalErrors(t *testing.T) {
+	cases := map[string]struct {
+		text   string
+		target any
+		path   Path
+		typ    reflect.Type
+	}{
+		"notAString":     {"a", new(string), Path{}, reflect.TypeFor[string]()},
+		"overflow":       {"(1 256)", new([]uint8), Path{1}, reflect.TypeFor[uint8]()},
+		"negativeUint":   {"-1", new(uint), Path{}, reflect.TypeFor[uint]()},
+		"improperList":   {"(1 . 2)", new([]int), Path{}, reflect.TypeFor[[]int]()},
+		"arrayTooLong":   {"(1 2 3)", new([2]int), Path{}, reflect.TypeFor[[2]int]()},
+		"badMapEntry":    {`(("a" 1) ("b"))`, new(map[string]int), Path{1}, reflect.TypeFor[map[string]int]()},
+		"badMapValue":    {`(("a" 1) ("b" x))`, new(map[string]int), Path{1, 1}, reflect.TypeFor[int]()},
+		"oddStruct":      {"(:X 1 :Y)", new(marshalPoint), Path{}, reflect.TypeFor[marshalPoint]()},
+		"notAKeyword":    {"(X 1)", new(marshalPoint), Path{0}, reflect.TypeFor[marshalPoint]()},
+		"deepField":      {`(:name "n" :origin (:X 1 :Y "2"))`, new(marshalRecord), Path{3, 3}, reflect.TypeFor[int]()},
+		"unmarshaler":    {"((celsius 1) (kelvin 2))", new([]celsius), Path{1}, reflect.TypeFor[celsius]()},
+		"notAPointer":    {"1", 0, Path{}, reflect.TypeFor[int]()},
+		"notAnEmptyFace": {"1", new(error), Path{}, reflect.TypeFor[error]()},
+	}
+	for name, c := range cases {
+		tree, _ := ReadSexpr(strings.NewReader(c.text))
+		err := Unmarshal(tree, c.target)
+		var ue UnmarshalError
+		if !errors.As(err, &ue) {
+			t.Errorf("%s: expected an UnmarshalError, got %v", name, err)
+			continue
+		}
+		assert(t.Errorf, ue.Path().String() == c.path.String(), "%s: expected the error at %s, got %s", name, c.path, ue.Path())
+		assert(t.Errorf, ue.Type() == c.typ, "%s: expected type %v, got %v", name, c.typ, ue.Type())
+		got, _ := GetAt(tree, ue.Path())
+		assert(t.Errorf, Equal(got, ue.Subtree()), "%s: the subtree %v is not at %s in %v", name, ue.Subtree(), ue.Path(), tree)
+	}
+}
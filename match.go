@@ -0,0 +1,73 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"math/big"
+	"strings"
+)
+
+// Cases hold a function for each shape of Tree, for Match to choose from.
+// The arguments are the same as those passed to the callbacks of the If* methods.
+//
+// Every one of the functions must be set.
+// That way, when a new shape is added, code that doesn't handle it fails loudly.
+type Cases[T any] struct {
+	Invalid func() T
+	Symbol  func(string) T
+	Number  func(int) T
+	Float   func(float64) T
+	BigInt  func(*big.Int) T
+	Rat     func(*big.Rat) T
+	String  func(string) T
+	List    func(List) T
+}
+
+// Match calls the function from cases that handles the shape of tree and returns its result.
+// It panics if any of the cases are missing, whatever the shape of tree.
+func Match[T any](tree Tree, cases Cases[T]) T {
+	cases.check()
+	switch tree.tag {
+	case symTreeSymbol:
+		return cases.Symbol(tree.symbol)
+	case symTreeNumber:
+		return cases.Number(tree.number)
+	case symTreeFloat:
+		return cases.Float(tree.float)
+	case symTreeBigInt:
+		return cases.BigInt(new(big.Int).Set(tree.bigInt))
+	case symTreeRat:
+		return cases.Rat(new(big.Rat).Set(tree.rat))
+	case symTreeString:
+		return cases.String(tree.symbol)
+	case symTreeList:
+		return cases.List(tree.list)
+	}
+	return cases.Invalid()
+}
+
+func (cases Cases[T]) check() {
+	var missing []string
+	for _, c := range []struct {
+		name string
+		set  bool
+	}{
+		{"Invalid", cases.Invalid != nil},
+		{"Symbol", cases.Symbol != nil},
+		{"Number", cases.Number != nil},
+		{"Float", cases.Float != nil},
+		{"BigInt", cases.BigInt != nil},
+		{"Rat", cases.Rat != nil},
+		{"String", cases.String != nil},
+		{"List", cases.List != nil},
+	} {
+		if !c.set {
+			missing = append(missing, c.name)
+		}
+	}
+	if len(missing) > 0 {
+		panic("symtree: Cases missing " + strings.Join(missing, ", "))
+	}
+}
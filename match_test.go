@@ -0,0 +1,67 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+)
+
+// describe builds Cases that describe a Tree's shape and contents.
+func describe() Cases[string] {
+	return Cases[string]{
+		Invalid: func() string { return "invalid" },
+		Symbol:  func(name string) string { return "symbol " + name },
+		Number:  func(n int) string { return fmt.Sprint("number ", n) },
+		Float:   func(f float64) string { return fmt.Sprint("float ", f) },
+		BigInt:  func(n *big.Int) string { return fmt.Sprint("bigInt ", n) },
+		Rat:     func(r *big.Rat) string { return fmt.Sprint("rational ", r) },
+		String:  func(text string) string { return "string " + text },
+		List:    func(l List) string { return fmt.Sprint("list of ", l.Len()) },
+	}
+}
+
+func TestMatchCallsTheCaseForTheShape(t *testing.T) {
+	cases := map[string]Tree{
+		"invalid":                               {},
+		"symbol x":                              Sym("x"),
+		"number 13":                             Num(13),
+		"float 1.5":                             Float(1.5),
+		"bigInt 123456789012345678901234567890": BigInt(hugeInt()),
+		"rational 1/3":                          Rat(big.NewRat(1, 3)),
+		"string a b":                            Str("a b"),
+		"list of 2":                             Lst(Sym("a"), Sym("b")),
+	}
+	for expected, tree := range cases {
+		actual := Match(tree, describe())
+		assert(t.Errorf, actual == expected, "expected %q, got %q", expected, actual)
+	}
+}
+
+func TestMatchPassesCopiesOfBigNumbers(t *testing.T) {
+	tree := BigInt(hugeInt())
+	cases := describe()
+	cases.BigInt = func(n *big.Int) string { return n.Add(n, n).String() }
+
+	Match(tree, cases)
+
+	assert(t.Errorf, Equal(tree, BigInt(hugeInt())), "the tree changed to %v", tree)
+}
+
+func TestMatchPanicsWhenACaseIsMissing(t *testing.T) {
+	cases := describe()
+	cases.Rat = nil
+	cases.String = nil
+
+	var message any
+	func() {
+		defer func() { message = recover() }()
+		Match(Sym("x"), cases)
+	}()
+
+	expected := "symtree: Cases missing Rat, String"
+	assert(t.Errorf, message == expected, "expected a panic with %q, got %v", expected, message)
+}
@@ -0,0 +1,105 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// A Path addresses a subtree by the indices of the list elements leading to it.
+// The empty Path addresses the whole Tree.
+// The tail of an improper list has the index right after the last element.
+//
+// In text form, each index is preceded by a slash, so that /1/2 is the third element of the second element.
+// The empty Path is written as a lone slash.
+type Path []int
+
+// ParsePath reads a Path from its text form.
+func ParsePath(text string) (Path, error) {
+	if text == "/" {
+		return Path{}, nil
+	}
+	if !strings.HasPrefix(text, "/") {
+		return nil, fmt.Errorf("invalid path %q: it should start with a slash", text)
+	}
+	parts := strings.Split(text[1:], "/")
+	p := make(Path, len(parts))
+	for i, part := range parts {
+		index, err := strconv.Atoi(part)
+		if err != nil || index < 0 || part != strconv.Itoa(index) {
+			return nil, fmt.Errorf("invalid path %q: %q is not an index", text, part)
+		}
+		p[i] = index
+	}
+	return p, nil
+}
+
+// String returns the text form of the Path.
+func (p Path) String() string {
+	if len(p) == 0 {
+		return "/"
+	}
+	var b strings.Builder
+	for _, index := range p {
+		b.WriteByte('/')
+		b.WriteString(strconv.Itoa(index))
+	}
+	return b.String()
+}
+
+// GetAt returns the subtree of tree at the Path.
+// If there's none, the result is an invalid Tree and a NoSuchPath error.
+func GetAt(tree Tree, p Path) (Tree, error) {
+	for depth, index := range p {
+		l, err := pathStep(tree, p[:depth+1])
+		if err != nil {
+			return Tree{}, err
+		}
+		tree = l.elem(index)
+	}
+	return tree, nil
+}
+
+// SetAt returns a Tree with the subtree at the Path replaced by t.
+// The rest of tree is shared with the result.
+// If there's no subtree at the Path, the result is an invalid Tree and a NoSuchPath error.
+func SetAt(tree Tree, p Path, t Tree) (Tree, error) {
+	return UpdateAt(tree, p, func(Tree) Tree { return t })
+}
+
+// UpdateAt returns a Tree with the subtree at the Path replaced by the result of calling f on it.
+// The rest of tree is shared with the result.
+// If there's no subtree at the Path, f is not called, and the result is an invalid Tree and a NoSuchPath error.
+func UpdateAt(tree Tree, p Path, f func(Tree) Tree) (Tree, error) {
+	return updateAt(tree, p, 0, f)
+}
+
+func updateAt(tree Tree, p Path, depth int, f func(Tree) Tree) (Tree, error) {
+	if depth == len(p) {
+		return f(tree), nil
+	}
+	l, err := pathStep(tree, p[:depth+1])
+	if err != nil {
+		return Tree{}, err
+	}
+	index := p[depth]
+	elem, err := updateAt(l.elem(index), p, depth+1, f)
+	if err != nil {
+		return Tree{}, err
+	}
+	return l.setElem(index, elem).Tree(), nil
+}
+
+// pathStep checks that tree is a list with an element at the last index of the Path.
+func pathStep(tree Tree, p Path) (List, error) {
+	index := p[len(p)-1]
+	if tree.tag != symTreeList || index < 0 || index >= tree.list.extent() {
+		return List{}, noSuchPath{path: slices.Clone(p), subtree: tree}
+	}
+	return tree.list, nil
+}
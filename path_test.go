@@ -0,0 +1,137 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPathText(t *testing.T) {
+	cases := map[string]Path{
+		"/":       {},
+		"/0":      {0},
+		"/1/2":    {1, 2},
+		"/10/0/3": {10, 0, 3},
+	}
+	for text, path := range cases {
+		assert(t.Errorf, path.String() == text, "expected %v to be written as %q, got %q", []int(path), text, path.String())
+
+		parsed, err := ParsePath(text)
+		assert(t.Errorf, err == nil, "%q: unexpected error: %v", text, err)
+		assert(t.Errorf, slices.Equal(parsed, path), "expected %q to be read as %v, got %v", text, []int(path), []int(parsed))
+	}
+}
+
+func TestParsePathFails(t *testing.T) {
+	for _, text := range []string{"", "1/2", "/1/", "//", "/-1", "/a", "/01", "/+1"} {
+		_, err := ParsePath(text)
+		assert(t.Errorf, err != nil, "%q: expected an error", text)
+	}
+}
+
+func TestGetAt(t *testing.T) {
+	tree := Lst(Sym("a"), Lst(Sym("b"), Num(1), Lst(Num(2))))
+	cases := map[string]Tree{
+		"/":      tree,
+		"/0":     Sym("a"),
+		"/1/1":   Num(1),
+		"/1/2/0": Num(2),
+		"/1/2":   Lst(Num(2)),
+		"/1":     Lst(Sym("b"), Num(1), Lst(Num(2))),
+	}
+	for text, expected := range cases {
+		path, _ := ParsePath(text)
+
+		actual, err := GetAt(tree, path)
+
+		assert(t.Errorf, err == nil, "%s: unexpected error: %v", text, err)
+		assert(t.Errorf, Equal(actual, expected), "%s: expected %v, got %v", text, expected, actual)
+	}
+}
+
+func TestSetAtAndUpdateAt(t *testing.T) {
+	tree := Lst(Sym("a"), Lst(Sym("b"), Num(1)))
+	wrap := func(t Tree) Tree { return Lst(Sym("wrapped"), t) }
+	cases := map[string]struct {
+		actual   func() (Tree, error)
+		expected Tree
+	}{
+		"setRoot":    {func() (Tree, error) { return SetAt(tree, Path{}, Sym("x")) }, Sym("x")},
+		"setElement": {func() (Tree, error) { return SetAt(tree, Path{0}, Sym("x")) }, Lst(Sym("x"), Lst(Sym("b"), Num(1)))},
+		"setDeep":    {func() (Tree, error) { return SetAt(tree, Path{1, 1}, Num(2)) }, Lst(Sym("a"), Lst(Sym("b"), Num(2)))},
+		"updateRoot": {func() (Tree, error) { return UpdateAt(tree, Path{}, wrap) }, wrap(tree)},
+		"updateDeep": {func() (Tree, error) { return UpdateAt(tree, Path{1, 0}, wrap) }, Lst(Sym("a"), Lst(wrap(Sym("b")), Num(1)))},
+	}
+	for name, c := range cases {
+		actual, err := c.actual()
+		assert(t.Errorf, err == nil, "%s: unexpected error: %v", name, err)
+		assert(t.Errorf, Equal(actual, c.expected), "%s: expected %v, got %v", name, c.expected, actual)
+	}
+	assert(t.Errorf, Equal(tree, Lst(Sym("a"), Lst(Sym("b"), Num(1)))), "the original tree changed: %v", tree)
+}
+
+func TestPathsThatDontExist(t *testing.T) {
+	tree := Lst(Sym("a"), Lst(Sym("b"), Num(1)))
+	cases := map[string]struct {
+		path    Path
+		failsAt Path
+		subtree Tree
+		message string
+	}{
+		"outOfRange": {Path{2}, Path{2}, tree, "no subtree at /2: / has 2 elements"},
+		"negative":   {Path{1, -1}, Path{1, -1}, Lst(Sym("b"), Num(1)), "no subtree at /1/-1: /1 has 2 elements"},
+		"atom":       {Path{0, 0, 1}, Path{0, 0}, Sym("a"), "no subtree at /0/0: /0 is not a list"},
+	}
+	for name, c := range cases {
+		called := false
+		results := map[string]func() (Tree, error){
+			"GetAt": func() (Tree, error) { return GetAt(tree, c.path) },
+			"SetAt": func() (Tree, error) { return SetAt(tree, c.path, Sym("x")) },
+			"UpdateAt": func() (Tree, error) {
+				return UpdateAt(tree, c.path, func(t Tree) Tree { called = true; return t })
+			},
+		}
+		for fun, result := range results {
+			actual, err := result()
+
+			assert(t.Errorf, Equal(actual, Tree{}), "%s: %s should return an invalid tree, got %v", name, fun, actual)
+			noPath, ok := err.(NoSuchPath)
+			if !ok {
+				t.Errorf("%s: %s should fail with a NoSuchPath error, got %v", name, fun, err)
+				continue
+			}
+			assert(t.Errorf, slices.Equal(noPath.Path(), c.failsAt), "%s: %s: expected to fail at %v, got %v", name, fun, c.failsAt, noPath.Path())
+			assert(t.Errorf, Equal(noPath.Subtree(), c.subtree), "%s: %s: expected subtree %v, got %v", name, fun, c.subtree, noPath.Subtree())
+			assert(t.Errorf, err.Error() == c.message, "%s: %s: expected message %q, got %q", name, fun, c.message, err.Error())
+		}
+		assert(t.Errorf, !called, "%s: UpdateAt should not call the function", name)
+	}
+}
+
+func TestZipperPath(t *testing.T) {
+	tree := Lst(Sym("a"), Lst(Sym("b"), Num(1)))
+	z, _ := NewZipper(tree).Down(1)
+	z, _ = z.Down(1)
+
+	assert(t.Errorf, slices.Equal(z.Path(), Path{1, 1}), "expected path /1/1, got %v", z.Path())
+	focus, _ := GetAt(tree, z.Path())
+	assert(t.Errorf, Equal(focus, z.Focus()), "expected %v at the path, got %v", z.Focus(), focus)
+	assert(t.Errorf, len(NewZipper(tree).Path()) == 0, "the path of the root should be empty")
+}
+
+func TestPathsIntoImproperLists(t *testing.T) {
+	tree := Lst(DottedLst([]Tree{Sym("a")}, Sym("b")))
+
+	tail, err := GetAt(tree, Path{0, 1})
+	assert(t.Errorf, err == nil && Equal(tail, Sym("b")), "expected the tail b, got %v and error %v", tail, err)
+
+	updated, err := SetAt(tree, Path{0, 1}, Sym("c"))
+	assert(t.Errorf, err == nil && sexpr{updated}.String() == "((a . c))", "expected ((a . c)), got %v and error %v", updated, err)
+
+	_, err = GetAt(tree, Path{0, 2})
+	_, isNoSuchPath := err.(NoSuchPath)
+	assert(t.Errorf, isNoSuchPath, "expected a NoSuchPath error, got %v", err)
+}
@@ -4,6 +4,8 @@
 
 package symtree
 
+import "math/big"
+
 // A Pattern is something a tree can be matched against or built from.
 // A pattern is like a tree with named holes.
 // The same hole name can appear in more than one place in a pattern.
@@ -33,11 +35,15 @@ func FromExample(holes []string, expr Tree) Pattern {
 
 func fromList(holes []string, p *Pattern) func(List) {
 	return func(l List) {
-		children := make(listPattern, l.Len())
+		children := make([]Pattern, l.Len())
 		for i := range children {
 			children[i] = FromExample(holes, l.At(i))
 		}
-		*p = children
+		lp := listPattern{elems: children}
+		if tail, improper := l.Tail(); improper {
+			lp.tail = FromExample(holes, tail)
+		}
+		*p = lp
 	}
 }
 
@@ -51,34 +57,69 @@ func fromSymbol(holes []string, p *Pattern) func(string) {
 	}
 }
 
-type listPattern []Pattern
+// A listPattern matches the elements of a list and, for an improper list, its tail.
+// The tail is nil for proper lists.
+//
+// As in Lisp, the tail of a pattern matches whatever follows the elements, so (x . rest) matches (1 2 3) with rest bound to (2 3).
+// That is the inverse of Substitute, which splices a list substituted for the tail into the result.
+type listPattern struct {
+	elems []Pattern
+	tail  Pattern
+}
 
 var _ Pattern = listPattern{}
 
 func (lp listPattern) Match(tree Tree, match map[string]Tree) error {
-	var err error
-	tree.IfInvalid(func() { err = atomCannotMatchList{} })
-	tree.IfSymbol(func(_ string) { err = atomCannotMatchList{} })
-	tree.IfNumber(func(_ int) { err = atomCannotMatchList{} })
-	tree.IfList(func(example List) {
-		if len(lp) != example.Len() {
-			err = lenMismatch{expected: len(lp), got: example.Len()}
-			return
-		}
-		for i := 0; err == nil && i < len(lp); i++ {
-			err = lp[i].Match(example.At(i), match)
-		}
+	atom := func() error { return atomCannotMatchList{} }
+	return Match(tree, Cases[error]{
+		Invalid: atom,
+		Symbol:  func(string) error { return atom() },
+		Number:  func(int) error { return atom() },
+		Float:   func(float64) error { return atom() },
+		BigInt:  func(*big.Int) error { return atom() },
+		Rat:     func(*big.Rat) error { return atom() },
+		String:  func(string) error { return atom() },
+		List: func(example List) error {
+			n := len(lp.elems)
+			if n > example.Len() || lp.tail == nil && n != example.Len() {
+				return lenMismatch{expected: n, got: example.Len(), atLeast: lp.tail != nil}
+			}
+			tail, improper := example.Tail()
+			if improper && lp.tail == nil {
+				return tailMismatch{tail: tail}
+			}
+			for i, elem := range example.All() {
+				if i == n {
+					break
+				}
+				if err := lp.elems[i].Match(elem, match); err != nil {
+					return err
+				}
+			}
+			if lp.tail == nil {
+				return nil
+			}
+			if n == example.Len() && improper {
+				return lp.tail.Match(tail, match)
+			}
+			return lp.tail.Match(example.Slice(n, example.Len()).Tree(), match)
+		},
 	})
-	return err
 }
 
+// Substitute builds the list from the elements and the tail.
+// As with DottedLst, a list substituted for the tail gets its elements spliced in.
 func (lp listPattern) Substitute(match map[string]Tree) (Tree, error) {
 	var err error
-	elems := make([]Tree, len(lp))
-	for i := 0; err == nil && i < len(lp); i++ {
-		elems[i], err = lp[i].Substitute(match)
+	elems := make([]Tree, len(lp.elems))
+	for i := 0; err == nil && i < len(lp.elems); i++ {
+		elems[i], err = lp.elems[i].Substitute(match)
+	}
+	if err != nil || lp.tail == nil {
+		return Lst(elems...), err
 	}
-	return Lst(elems...), err
+	tail, err := lp.tail.Substitute(match)
+	return DottedLst(elems, tail), err
 }
 
 type holePattern struct {
@@ -251,3 +251,67 @@ func TestListPatternSubstitute(t *testing.T) {
 		"expected %v, got %v", expectedTree, tree,
 	)
 }
+
+func TestListPatternOnImproperLists(t *testing.T) {
+	example := DottedLst([]Tree{Sym("f"), Sym("x")}, Sym("y"))
+	cases := map[string]struct {
+		tree     Tree
+		expected Tree
+	}{
+		"atomTail":        {DottedLst([]Tree{Sym("f"), Num(1)}, Num(2)), Num(2)},
+		"noMoreElements":  {Lst(Sym("f"), Num(1)), Lst()},
+		"moreElements":    {Lst(Sym("f"), Num(1), Num(2), Num(3)), Lst(Num(2), Num(3))},
+		"moreAndAtomTail": {DottedLst([]Tree{Sym("f"), Num(1), Num(2)}, Num(3)), DottedLst([]Tree{Num(2)}, Num(3))},
+	}
+	for name, c := range cases {
+		match := map[string]Tree{}
+		err := FromExample([]string{"x", "y"}, example).Match(c.tree, match)
+		assert(t.Errorf, err == nil && Equal(match["y"], c.expected), "%s: expected y to be bound to %v, got %v, %v", name, c.expected, match["y"], err)
+	}
+
+	err := FromExample([]string{"x"}, Lst(Sym("f"), Sym("x"))).Match(DottedLst([]Tree{Sym("f"), Num(1)}, Num(2)), map[string]Tree{})
+	tm, isTailMismatch := err.(TailMismatch)
+	assert(t.Errorf, isTailMismatch && Equal(tm.UnexpectedTail(), Num(2)), "expected a TailMismatch with the tail 2, got %v", err)
+
+	err = FromExample([]string{"x", "y"}, example).Match(Lst(Sym("f")), map[string]Tree{})
+	_, isLengthMismatch := err.(LengthMismatch)
+	assert(t.Errorf, isLengthMismatch, "expected a LengthMismatch for a list that is too short, got %v", err)
+
+	err = FromExample([]string{"x", "y"}, example).Match(DottedLst([]Tree{Sym("f"), Num(1)}, Num(2)), map[string]Tree{"y": Num(3)})
+	_, isAlreadyBound := err.(SymbolAlreadyBound)
+	assert(t.Errorf, isAlreadyBound, "expected the tail to be matched against the bound hole, got %v", err)
+}
+
+func TestListPatternSubstituteImproper(t *testing.T) {
+	pattern := FromExample([]string{"x", "y"}, DottedLst([]Tree{Sym("f"), Sym("x")}, Sym("y")))
+	cases := map[string]struct {
+		y        Tree
+		expected Tree
+	}{
+		"atomTail": {Num(2), DottedLst([]Tree{Sym("f"), Num(1)}, Num(2))},
+		"listTail": {Lst(Num(2), Num(3)), Lst(Sym("f"), Num(1), Num(2), Num(3))},
+	}
+	for name, c := range cases {
+		tree, err := pattern.Substitute(map[string]Tree{"x": Num(1), "y": c.y})
+		assert(t.Errorf, err == nil && Equal(tree, c.expected), "%s: expected %v, got %v, %v", name, c.expected, tree, err)
+	}
+
+	_, err := pattern.Substitute(map[string]Tree{"x": Num(1)})
+	_, isNotBound := err.(NotBound)
+	assert(t.Errorf, isNotBound, "expected a NotBound error for the tail, got %v", err)
+}
+
+func TestListPatternMatchesWhatItSubstitutes(t *testing.T) {
+	pattern := FromExample([]string{"x", "y"}, DottedLst([]Tree{Sym("f"), Sym("x")}, Sym("y")))
+	for _, y := range []Tree{Num(2), Lst(), Lst(Num(2), Num(3)), DottedLst([]Tree{Num(2)}, Num(3))} {
+		bindings := map[string]Tree{"x": Num(1), "y": y}
+		tree, err := pattern.Substitute(bindings)
+		if err != nil {
+			t.Fatalf("unexpected error substituting %v: %v", y, err)
+		}
+
+		match := map[string]Tree{}
+		err = pattern.Match(tree, match)
+		assert(t.Errorf, err == nil && Equal(match["x"], Num(1)) && Equal(match["y"], y), "expected %v to match back with y bound to %v, got %v, %v", tree, y, match, err)
+	}
+}
@@ -0,0 +1,55 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import "fmt"
+
+// A Position is a location in s-expression text.
+type Position struct {
+	// Offset counts bytes, starting at 0.
+	Offset int
+	// Line counts lines, starting at 1.
+	Line int
+	// Column counts runes within the line, starting at 1.
+	Column int
+}
+
+// String returns the position in the line:column format.
+func (pos Position) String() string {
+	return fmt.Sprintf("%d:%d", pos.Line, pos.Column)
+}
+
+// advance returns the position right after chr, when chr is found at pos.
+// The rune takes size bytes in the input.
+func (pos Position) advance(chr rune, size int) Position {
+	pos.Offset += size
+	if chr == '\n' {
+		pos.Line++
+		pos.Column = 1
+	} else {
+		pos.Column++
+	}
+	return pos
+}
+
+// A Span is a stretch of s-expression text.
+type Span struct {
+	// File names the source of the text.
+	// It can be empty.
+	File string
+	// Start is the position of the first rune of the text.
+	Start Position
+	// End is the position right after the last rune of the text.
+	End Position
+}
+
+// String returns the span in the file:line:column-line:column format.
+// The file part is left out when the file name is empty.
+func (span Span) String() string {
+	if span.File == "" {
+		return fmt.Sprintf("%s-%s", span.Start, span.End)
+	}
+	return fmt.Sprintf("%s:%s-%s", span.File, span.Start, span.End)
+}
@@ -0,0 +1,324 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strings"
+)
+
+// A Printer writes Trees in s-expression form, spread over as many lines as needed.
+//
+// A list that fits within the line width stays on one line.
+// A longer one is broken, with each element on a line of its own,
+// and the same goes for each of its elements.
+// Whatever the layout, the output reads back as the same Tree.
+type Printer struct {
+	// Width is the line width the Printer tries to stay within.
+	// When it is zero, DefaultWidth is used instead.
+	Width int
+
+	// Indent is how many columns further than its opening parenthesis the elements of a broken list start.
+	// The head of the list stays on the line of the parenthesis.
+	//
+	// When it is zero, the first element after the head stays on the same line as well,
+	// with the rest of the elements aligned with it.
+	Indent int
+
+	// Rules override how lists are broken, based on the symbol at their head.
+	// Lists whose head has no rule are broken according to Indent.
+	Rules IndentRules
+}
+
+// IndentRules map head symbols to the rules for breaking lists that start with them.
+type IndentRules map[string]IndentRule
+
+// An IndentRule says how to break a list across lines.
+//
+// For example, the rule for let in LispIndentRules is {Distinguished: 1, Body: 2}, which gives
+//
+//	(let ((x 1)
+//	      (y 2))
+//	  (+ x y))
+type IndentRule struct {
+	// Distinguished is the number of elements after the head that stay on its line.
+	// When they don't fit, they are aligned with the first of them.
+	Distinguished int
+
+	// Body is how many columns further than the opening parenthesis the remaining elements start.
+	// When it is zero, they are aligned with the first element after the head instead.
+	Body int
+}
+
+// LispIndentRules returns rules for some common Lisp special forms, in the style of Emacs.
+func LispIndentRules() IndentRules {
+	body := IndentRule{Distinguished: 1, Body: 2}
+	return IndentRules{
+		"define": body,
+		"defun":  IndentRule{Distinguished: 2, Body: 2},
+		"lambda": body,
+		"let":    body,
+		"let*":   body,
+		"letrec": body,
+		"when":   body,
+		"unless": body,
+		"if":     IndentRule{Distinguished: 1},
+		"cond":   IndentRule{Body: 1},
+		"begin":  IndentRule{Body: 2},
+		"progn":  IndentRule{Body: 2},
+	}
+}
+
+// DefaultWidth is the line width used by a Printer that doesn't have one set.
+const DefaultWidth = 80
+
+// Print writes the s-expression form of t into dst.
+// The output does not end with a line break.
+func (p Printer) Print(dst io.Writer, t Tree) (n int, err error) {
+	pr := printer{Printer: p}
+	w := writer{dst: dst}
+	w.Write(layout(pr.doc(t), p.width()))
+	return w.n, w.err
+}
+
+// Reformat reads all the forms in src and prints them into dst, each starting on a new line.
+// Comments are kept, as close to where they were as the layout allows.
+// So are single blank lines between top-level forms and comments.
+//
+// Nothing is written when src is malformed.
+// The error is then the same as a Decoder would return.
+func (p Printer) Reformat(dst io.Writer, src io.Reader) (n int, err error) {
+	var comments []comment
+	dec := NewDecoder(src)
+	dec.RecordSpans("")
+	dec.r.comments = &comments
+
+	var forms []Tree
+	for dec.More() {
+		form, err := dec.Decode()
+		if err != nil {
+			return 0, err
+		}
+		forms = append(forms, form)
+	}
+	if _, err := dec.Decode(); err != io.EOF {
+		return 0, err
+	}
+
+	pr := printer{Printer: p, comments: comments}
+	w := writer{dst: dst}
+	lastLine := 0
+	writeLine := func(text string, span Span) {
+		if lastLine > 0 && span.Start.Line > lastLine+1 {
+			w.Write("\n")
+		}
+		w.Write(text + "\n")
+		lastLine = span.End.Line
+	}
+	for i, form := range forms {
+		span, _ := form.Span()
+		for _, c := range pr.takeCommentsBefore(span.Start.Offset) {
+			writeLine(c.text, c.span)
+		}
+		next := math.MaxInt
+		if i+1 < len(forms) {
+			nextSpan, _ := forms[i+1].Span()
+			next = nextSpan.Start.Offset
+		}
+		d, _ := pr.elemDoc(form, next)
+		writeLine(layout(d, p.width()), span)
+	}
+	for _, c := range pr.comments {
+		writeLine(c.text, c.span)
+	}
+	return w.n, w.err
+}
+
+func (p Printer) width() int {
+	if p.Width == 0 {
+		return DefaultWidth
+	}
+	return p.Width
+}
+
+// A printer is a Printer in the middle of printing.
+// It holds the comments that are still to be printed, in the order they appeared in.
+type printer struct {
+	Printer
+	comments []comment
+}
+
+func (p *printer) doc(t Tree) doc {
+	var d doc = docText(atomText(t))
+	t.IfList(func(l List) { d = p.listDoc(l, t.span) })
+	return d
+}
+
+// listDoc builds the doc for a list.
+// The span says where the list was read from, if anywhere,
+// so that the comments inside it get printed within it as well.
+func (p *printer) listDoc(l List, span *Span) doc {
+	end := math.MaxInt
+	if span != nil {
+		end = span.End.Offset
+	}
+	trees := l.trees()
+	if tail, ok := l.Tail(); ok {
+		trees = append(trees, tail)
+	}
+	elems := make([]doc, len(trees))
+	endsWithLineComment := false
+	for i := range elems {
+		limit := end
+		if i+1 < len(trees) {
+			if next, ok := trees[i+1].Span(); ok {
+				limit = next.Start.Offset
+			}
+		}
+		elems[i], endsWithLineComment = p.elemDoc(trees[i], limit)
+	}
+	if !l.IsProper() {
+		elems[len(elems)-1] = docConcat{docText(". "), elems[len(elems)-1]}
+	}
+
+	if rest := p.takeCommentsBefore(end); len(rest) > 0 {
+		var restDoc doc
+		restDoc, endsWithLineComment = commentsDoc(rest)
+		if len(elems) == 0 {
+			elems = append(elems, restDoc)
+		} else {
+			elems[len(elems)-1] = docConcat{elems[len(elems)-1], softLine, restDoc}
+		}
+	}
+	if len(elems) == 0 {
+		return docText("()")
+	}
+	if endsWithLineComment {
+		elems[len(elems)-1] = docConcat{elems[len(elems)-1], hardLine}
+	}
+	if rule, ok := p.rule(l.At(0)); ok && len(elems) > 1 {
+		return docGroup{rule.doc(elems[0], elems[1:])}
+	}
+
+	var body doc
+	switch {
+	case p.Indent > 0:
+		body = docAlign{docConcat{docText("("), docNest{p.Indent, docConcat{elems[0], lines(elems[1:])}}, docText(")")}}
+	case len(elems) > 2 && !isList(l.At(0)):
+		body = docConcat{docText("("), elems[0], docText(" "), docAlign{joinLines(elems[1:])}, docText(")")}
+	default:
+		body = docConcat{docText("("), docAlign{joinLines(elems)}, docText(")")}
+	}
+	return docGroup{body}
+}
+
+// elemDoc builds the doc for t, together with the comments that come right before it
+// and those that follow it on the same line, up to the limit offset.
+// It also reports whether the doc ends with a line comment.
+func (p *printer) elemDoc(t Tree, limit int) (doc, bool) {
+	span, ok := t.Span()
+	if !ok {
+		return p.doc(t), false
+	}
+	d := docConcat{}
+	if before := p.takeCommentsBefore(span.Start.Offset); len(before) > 0 {
+		beforeDoc, _ := commentsDoc(before)
+		d = append(d, beforeDoc, softLine)
+	}
+	d = append(d, p.doc(t))
+
+	endsWithLineComment := false
+	for len(p.comments) > 0 && p.comments[0].span.Start.Line == span.End.Line && p.comments[0].span.Start.Offset < limit {
+		c := p.comments[0]
+		p.comments = p.comments[1:]
+		d = append(d, docText(" "), docText(c.text))
+		endsWithLineComment = isLineComment(c)
+		if endsWithLineComment {
+			d = append(d, docBreakParent{})
+		}
+	}
+	return d, endsWithLineComment
+}
+
+func (p *printer) takeCommentsBefore(offset int) []comment {
+	n := 0
+	for n < len(p.comments) && p.comments[n].span.Start.Offset < offset {
+		n++
+	}
+	taken := p.comments[:n]
+	p.comments = p.comments[n:]
+	return taken
+}
+
+// commentsDoc builds the doc for a run of comments, each on a line of its own.
+// It also reports whether the last of them is a line comment.
+func commentsDoc(comments []comment) (doc, bool) {
+	d := docConcat{}
+	for i, c := range comments {
+		if i > 0 {
+			d = append(d, hardLine)
+		}
+		d = append(d, docText(c.text))
+		if isLineComment(c) {
+			d = append(d, docBreakParent{})
+		}
+	}
+	return d, isLineComment(comments[len(comments)-1])
+}
+
+func isLineComment(c comment) bool {
+	return strings.HasPrefix(c.text, ";")
+}
+
+func (p Printer) rule(head Tree) (IndentRule, bool) {
+	var (
+		rule IndentRule
+		ok   bool
+	)
+	head.IfSymbol(func(name string) { rule, ok = p.Rules[name] })
+	return rule, ok
+}
+
+func (rule IndentRule) doc(head doc, args []doc) doc {
+	if rule.Body <= 0 {
+		return docConcat{docText("("), head, docText(" "), docAlign{joinLines(args)}, docText(")")}
+	}
+	distinguished := args[:min(max(rule.Distinguished, 0), len(args))]
+	body := args[len(distinguished):]
+
+	d := docConcat{docText("("), head}
+	if len(distinguished) > 0 {
+		d = append(d, docText(" "), docGroup{docAlign{joinLines(distinguished)}})
+	}
+	d = append(d, docNest{rule.Body, lines(body)}, docText(")"))
+	return docAlign{d}
+}
+
+// joinLines puts line breaks between the docs.
+func joinLines(docs []doc) doc {
+	return docConcat{docs[0], lines(docs[1:])}
+}
+
+// lines puts a line break before each of the docs.
+func lines(docs []doc) doc {
+	joined := make(docConcat, 0, 2*len(docs))
+	for _, d := range docs {
+		joined = append(joined, softLine, d)
+	}
+	return joined
+}
+
+func isList(t Tree) bool {
+	return t.tag == symTreeList
+}
+
+// atomText returns the s-expression form of an atom, the same as WriteSexpr would.
+func atomText(t Tree) string {
+	var b bytes.Buffer
+	WriteSexpr(&b, t)
+	return b.String()
+}
@@ -0,0 +1,236 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestPrinter(t *testing.T) {
+	type testcase struct {
+		printer  Printer
+		tree     Tree
+		expected string
+	}
+	defn := Lst(
+		Sym("define"), Lst(Sym("square"), Sym("x")),
+		Lst(Sym("*"), Sym("x"), Sym("x")),
+	)
+	cases := map[string]testcase{
+		"atom":      {Printer{}, Sym("a b"), "|a b|"},
+		"emptyList": {Printer{}, Lst(), "()"},
+		"fits":      {Printer{}, defn, "(define (square x) (* x x))"},
+		"fitsExactly": {
+			Printer{Width: 27}, defn,
+			"(define (square x) (* x x))",
+		},
+		"aligned": {
+			Printer{Width: 26}, defn,
+			"(define (square x)\n        (* x x))",
+		},
+		"alignedNested": {
+			Printer{Width: 20},
+			Lst(Sym("define"), Lst(Sym("f"), Sym("x")), Lst(Sym("+"), Lst(Sym("*"), Sym("x"), Sym("x")), Lst(Sym("*"), Sym("y"), Sym("y")))),
+			"(define (f x)\n        (+ (* x x)\n           (* y y)))",
+		},
+		"listHead": {
+			Printer{Width: 8}, Lst(Lst(Sym("lambda"), Sym("x")), Num(1), Num(2)),
+			"((lambda\n  x)\n 1\n 2)",
+		},
+		"twoElements": {
+			Printer{Width: 10}, Lst(Sym("quote"), Lst(Sym("a"), Sym("b"))),
+			"(quote\n (a b))",
+		},
+		"indented": {
+			Printer{Width: 26, Indent: 2}, defn,
+			"(define\n  (square x)\n  (* x x))",
+		},
+		"indentedNested": {
+			Printer{Width: 10, Indent: 1}, defn,
+			"(define\n (square\n  x)\n (* x x))",
+		},
+		"innerGroupsStayFlat": {
+			Printer{Width: 20}, Lst(Sym("list"), Lst(Sym("a"), Sym("b")), Lst(Sym("c"), Sym("d")), Lst(Sym("e"), Sym("f"))),
+			"(list (a b)\n      (c d)\n      (e f))",
+		},
+		"improperFits": {
+			Printer{}, DottedLst([]Tree{Sym("a"), Sym("b")}, Sym("c")),
+			"(a b . c)",
+		},
+		"improperBroken": {
+			Printer{Width: 12}, DottedLst([]Tree{Sym("cons"), Lst(Sym("a"), Sym("b"))}, Lst(Sym("c"), Sym("d"))),
+			"(cons (a b)\n      c\n      d)",
+		},
+		"improperTail": {
+			Printer{Width: 10}, DottedLst([]Tree{Sym("pair"), Sym("first")}, Sym("second")),
+			"(pair first\n      . second)",
+		},
+	}
+
+	for name, kase := range cases {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			kase.printer.Print(&b, kase.tree)
+
+			assert(t.Errorf, kase.expected == b.String(), "expected\n%s\ngot\n%s", kase.expected, b.String())
+		})
+	}
+}
+
+func TestPrinterWithIndentRules(t *testing.T) {
+	type testcase struct {
+		tree     Tree
+		expected string
+	}
+	printer := Printer{Width: 20, Rules: LispIndentRules()}
+	cases := map[string]testcase{
+		"letFits": {
+			Lst(Sym("let"), Lst(Lst(Sym("x"), Num(1))), Sym("x")),
+			"(let ((x 1)) x)",
+		},
+		"let": {
+			Lst(Sym("let"), Lst(Lst(Sym("x"), Num(1)), Lst(Sym("y"), Num(2))), Lst(Sym("+"), Sym("x"), Sym("y"))),
+			"(let ((x 1) (y 2))\n  (+ x y))",
+		},
+		"letBrokenBindings": {
+			Lst(Sym("let"), Lst(Lst(Sym("xs"), Num(1)), Lst(Sym("ys"), Num(2)), Lst(Sym("zs"), Num(3))), Sym("xs")),
+			"(let ((xs 1)\n      (ys 2)\n      (zs 3))\n  xs)",
+		},
+		"if": {
+			Lst(Sym("if"), Lst(Sym("<"), Sym("x"), Num(0)), Lst(Sym("-"), Sym("x"), Num(1)), Sym("x")),
+			"(if (< x 0)\n    (- x 1)\n    x)",
+		},
+		"defun": {
+			Lst(Sym("defun"), Sym("sq"), Lst(Sym("x")), Lst(Sym("*"), Sym("x"), Sym("x"))),
+			"(defun sq (x)\n  (* x x))",
+		},
+		"cond": {
+			Lst(Sym("cond"), Lst(Sym("a"), Num(1)), Lst(Sym("b"), Num(2)), Lst(Sym("else"), Num(3))),
+			"(cond\n (a 1)\n (b 2)\n (else 3))",
+		},
+		"progn": {
+			Lst(Sym("progn"), Lst(Sym("print"), Num(1)), Lst(Sym("print"), Num(2))),
+			"(progn\n  (print 1)\n  (print 2))",
+		},
+		"noRule": {
+			Lst(Sym("list"), Lst(Sym("a"), Num(1)), Lst(Sym("b"), Num(2)), Lst(Sym("c"), Num(3))),
+			"(list (a 1)\n      (b 2)\n      (c 3))",
+		},
+		"nested": {
+			Lst(Sym("define"), Lst(Sym("f"), Sym("x")), Lst(Sym("let"), Lst(Lst(Sym("y"), Sym("x"))), Lst(Sym("*"), Sym("y"), Sym("y")))),
+			"(define (f x)\n  (let ((y x))\n    (* y y)))",
+		},
+	}
+
+	for name, kase := range cases {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			printer.Print(&b, kase.tree)
+
+			assert(t.Errorf, kase.expected == b.String(), "expected\n%s\ngot\n%s", kase.expected, b.String())
+		})
+	}
+}
+
+func TestPrinterOutputReadsBackAsTheSameTree(t *testing.T) {
+	tree := nestedTree(5)
+	rules := IndentRules{"node": {Distinguished: 1, Body: 2}}
+	for _, printer := range []Printer{{}, {Width: 1}, {Width: 30}, {Width: 30, Indent: 2}, {Width: 30, Rules: rules}} {
+		var b bytes.Buffer
+		printer.Print(&b, tree)
+
+		readBack, err := ReadSexpr(strings.NewReader(b.String()))
+
+		assert(t.Errorf, err == nil, "%+v: unexpected error: %s", printer, err)
+		assert(t.Errorf, Equal(tree, readBack), "%+v: output\n%s\nreads back as a different tree", printer, b.String())
+	}
+}
+
+func TestPrinterStaysWithinTheWidthWhenPossible(t *testing.T) {
+	tree := nestedTree(4)
+	for _, printer := range []Printer{{Width: 40, Indent: 1}, {Width: 40, Indent: 2}} {
+		var b bytes.Buffer
+		printer.Print(&b, tree)
+
+		for i, line := range strings.Split(b.String(), "\n") {
+			assert(t.Errorf, len(line) <= printer.Width, "%+v: line %d is too long: %q", printer, i+1, line)
+		}
+	}
+}
+
+// nestedTree builds a tree with lists nested depth levels deep and a mix of atoms.
+func nestedTree(depth int) Tree {
+	if depth == 0 {
+		return Lst(Sym("leaf"), Num(depth), Str("a string"))
+	}
+	return Lst(Sym("node"), Sym("a b"), nestedTree(depth-1), Float(1.5), nestedTree(depth-1))
+}
+
+func TestReformatKeepsComments(t *testing.T) {
+	type testcase struct {
+		input    string
+		expected string
+	}
+	printer := Printer{Width: 40, Rules: LispIndentRules()}
+	cases := map[string]testcase{
+		"empty":           {"", ""},
+		"onlyComments":    {"; a\n\n\n#| b |#", "; a\n\n#| b |#\n"},
+		"spacesCollapsed": {"(a   b\n c)", "(a b c)\n"},
+		"blankLinesKept":  {"(a)\n\n\n(b)\n(c)", "(a)\n\n(b)\n(c)\n"},
+		"formsSplit":      {"(a) (b)", "(a)\n(b)\n"},
+		"leading":         {"; about a\n(a)", "; about a\n(a)\n"},
+		"trailing":        {"(a) ; about a\n(b)", "(a) ; about a\n(b)\n"},
+		"trailingInList":  {"(a ; about a\n b)", "(a ; about a\n b)\n"},
+		"leadingInList":   {"(a\n ; about b\n b)", "(a\n ; about b\n b)\n"},
+		"lastInList":      {"(a b c ; about c\n)", "(a b\n   c ; about c\n   )\n"},
+		"beforeClose":     {"(a b c\n ; the end\n)", "(a b\n   c\n   ; the end\n   )\n"},
+		"emptyList":       {"( ; nothing\n)", "(; nothing\n )\n"},
+		"blockInline":     {"(a #| b |# c)", "(a #| b |# c)\n"},
+		"datumComment":    {"(a #;(b  c) d)", "(a #;(b  c) d)\n"},
+		"beforeTail":      {"(a ; about a\n . b)", "(a ; about a\n . b)\n"},
+		"inTail":          {"(a . ; about b\n b)", "(a ; about b\n . b)\n"},
+		"multilineBlock":  {"#| a\n   b |#\n(c)", "#| a\n   b |#\n(c)\n"},
+		"bodyComment": {
+			"(define (f x)\n  ; square it\n  (* x x))",
+			"(define (f x)\n  ; square it\n  (* x x))\n",
+		},
+	}
+
+	for name, kase := range cases {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+
+			_, err := printer.Reformat(&b, strings.NewReader(kase.input))
+
+			assert(t.Errorf, err == nil, "unexpected error: %s", err)
+			assert(t.Errorf, kase.expected == b.String(), "expected\n%s\ngot\n%s", kase.expected, b.String())
+		})
+	}
+}
+
+func TestReformatIsIdempotent(t *testing.T) {
+	input := "; header\n(define (f x) ; f\n  (let ((y x)) #| y |# (* y y y y y y y y y y y y y y y y)) ; body\n  ; end\n  )\n(a b)   (c d) ; cd\n"
+	printer := Printer{Width: 30, Rules: LispIndentRules()}
+
+	var once, twice bytes.Buffer
+	printer.Reformat(&once, strings.NewReader(input))
+	printer.Reformat(&twice, bytes.NewReader(once.Bytes()))
+
+	assert(t.Errorf, once.String() == twice.String(), "formatting again changed\n%s\ninto\n%s", once.String(), twice.String())
+}
+
+func TestReformatFailsOnMalformedInput(t *testing.T) {
+	var b bytes.Buffer
+
+	_, err := Printer{}.Reformat(&b, strings.NewReader("(a) (b"))
+
+	_, typeOK := err.(SyntaxError)
+	assert(t.Errorf, typeOK, "error returned should implement SyntaxError, got %v", err)
+	assert(t.Errorf, b.Len() == 0, "nothing should be written, got %q", b.String())
+}
@@ -6,41 +6,115 @@ package symtree
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"math"
+	"math/big"
 	"strconv"
+	"strings"
 	"unicode"
 	"unicode/utf8"
 )
 
 // ReadSexpr reads an s-expression form of a symtree from an io.Reader.
-// It is reasonable to expect the
+// It reads exactly one form and leaves src right after it, so it can be called again to read the next one.
+// When the input ends right after an atom, the atom is returned together with io.EOF.
+// A Decoder is more convenient for reading many forms from the same input.
+//
+// A symbol can be written between vertical bars, as in |a symbol|.
+// That way it can contain any text, including whitespace, parentheses or digits only.
+// The same escape sequences as in strings work inside the bars, with \| standing for a bar.
+//
+// A semicolon starts a comment that lasts until the end of the line.
+// Block comments start with #| and end with |#, and they can be nested.
+// A #; comments out the whole form that follows it, whatever its length.
+//
+// An improper list has a dot between its elements and its tail, as in (a b . c).
+// Elsewhere, a lone dot is a symbol.
+//
+// Strings are written between double quotes.
+// Inside a string a backslash starts an escape sequence.
+// The escapes \a, \b, \f, \n, \r, \t, \v, \\ and \" mean the same as in Go.
+// A \xHH escape stands for a single byte and a \u{H...} escape for a Unicode code point,
+// both given in hexadecimal.
+//
+// Malformed input results in a SyntaxError.
+// Its position is counted from wherever src was when ReadSexpr got called.
 func ReadSexpr(src io.RuneScanner) (Tree, error) {
-	r := reader{src: src}
+	r := newReader(src)
 	return r.parse()
 }
 
 type reader struct {
 	src io.RuneScanner
 	err error
+
+	// The position of the next rune to read and the one before the last read.
+	pos, prevPos Position
+
+	// When spans is set, every Tree read gets a Span in file.
+	spans bool
+	file  string
+
+	// When comments is not nil, the text of every comment skipped is appended to it.
+	// The raw buffer holds the text of the comment being skipped,
+	// with rawAdded being the length of the last rune added.
+	comments *[]comment
+	raw      *bytes.Buffer
+	rawAdded int
+
+	// Where the last lone dot was read, which separates the tail of an improper list from the elements.
+	dot Position
+
+	// A rune that was accepted and then pushed back.
+	// It is the next one read, before anything else in src.
+	pending               rune
+	hasPending, wasPended bool
+}
+
+func newReader(src io.RuneScanner) reader {
+	return reader{src: src, pos: Position{Line: 1, Column: 1}}
 }
 
 func (r *reader) parse() (Tree, error) {
-	r.skipWhile(unicode.IsSpace)
-	if r.peek() == '(' {
+	r.skipAtmosphere()
+	start := r.pos
+	tree, err := r.parseForm()
+	if r.spans && tree.tag != symTreeInvalid {
+		tree.span = &Span{File: r.file, Start: start, End: r.pos}
+	}
+	return tree, err
+}
+
+func (r *reader) parseForm() (Tree, error) {
+	switch r.peek() {
+	case '(':
 		return r.parseList()
+	case ')':
+		pos := r.pos
+		r.fail(pos, r.accept(), "a form", "unexpected )")
+		return Tree{}, r.err
+	case '"':
+		return r.parseString()
+	case '|':
+		return r.parseQuotedSymbol()
 	}
 	return r.parseAtom()
 }
 
 func (r *reader) parseList() (Tree, error) {
+	open := r.pos
 	r.accept()
-	elems := r.parseListElements()
+	elems, tail := r.parseListElements()
 	if r.peek() != ')' {
-		r.eofNotExpected()
+		r.unclosed(open, ")", "unclosed list")
+		pos := r.pos
+		r.fail(pos, r.accept(), ")", "more than one form after a dot")
 	}
-	return r.resultIfNoError(Lst(elems...))
+	r.accept()
+	return r.resultIfNoError(DottedLst(elems, tail))
 }
 
 func (r *reader) resultIfNoError(tree Tree) (Tree, error) {
@@ -50,45 +124,381 @@ func (r *reader) resultIfNoError(tree Tree) (Tree, error) {
 	return tree, nil
 }
 
-func (r *reader) eofNotExpected() {
+// unclosed turns reaching the end of the input into an error.
+// The error points at open, where the construct that should have been closed starts.
+func (r *reader) unclosed(open Position, expected, problem string) {
 	if r.err != io.EOF {
 		return
 	}
-	r.err = io.ErrUnexpectedEOF
+	r.err = unexpectedEOF{syntaxError{pos: open, found: endOfInput, expected: expected, problem: problem}}
+}
+
+// fail records a syntax error, unless an earlier error has already been recorded.
+func (r *reader) fail(pos Position, found rune, expected, problem string) {
+	r.setErr(syntaxError{pos: pos, found: found, expected: expected, problem: problem})
 }
 
-func (r *reader) parseListElements() []Tree {
-	r.skipWhile(unicode.IsSpace)
+// parseListElements reads the elements of a list, up to the closing parenthesis.
+// When the list is improper, it also returns the tail, which follows a dot.
+func (r *reader) parseListElements() ([]Tree, Tree) {
+	r.skipAtmosphere()
 
 	var elems []Tree
-	for r.err == nil && r.peek() != ')' {
-		elems = append(elems, r.parseListElem())
+	for r.more() && r.peek() != ')' {
+		start := r.pos
+		elem := r.parseListElem()
+		if r.dot != start {
+			elems = append(elems, elem)
+			continue
+		}
+		if len(elems) == 0 {
+			r.fail(start, '.', "a form", "dot at the start of a list")
+		}
+		return elems, r.parseTail()
 	}
-	return elems
+	return elems, Tree{}
 }
 
 func (r *reader) parseListElem() Tree {
 	elem, _ := r.parse()
-	r.skipWhile(unicode.IsSpace)
+	r.skipAtmosphere()
 	return elem
 }
 
+// parseTail reads the form after the dot in an improper list.
+func (r *reader) parseTail() Tree {
+	start := r.pos
+	if r.more() && r.peek() == ')' {
+		r.fail(start, ')', "a form", "missing form after a dot")
+	}
+	tail := r.parseListElem()
+	if r.dot == start {
+		r.fail(start, '.', "a form", "unexpected dot")
+	}
+	return tail
+}
+
+// skipAtmosphere skips whitespace and comments.
+func (r *reader) skipAtmosphere() {
+	for r.err == nil {
+		r.skipWhile(unicode.IsSpace)
+		start := r.pos
+		switch r.peek() {
+		case ';':
+			outermost := r.startComment()
+			r.skipWhile(isNotNewline)
+			r.endComment(outermost, start)
+		case '#':
+			outermost := r.startComment()
+			if !r.skipHashComment() {
+				r.dropComment(outermost)
+				return
+			}
+			r.endComment(outermost, start)
+		default:
+			return
+		}
+	}
+}
+
+// A comment is the text of a comment skipped while reading, together with where it was.
+type comment struct {
+	text string
+	span Span
+}
+
+// startComment starts keeping the text of the comment about to be skipped, if comments are being kept.
+// It reports whether this is the outermost comment, and not one inside a datum comment.
+func (r *reader) startComment() bool {
+	if r.comments == nil || r.raw != nil {
+		return false
+	}
+	r.raw = new(bytes.Buffer)
+	return true
+}
+
+func (r *reader) endComment(outermost bool, start Position) {
+	if !outermost {
+		return
+	}
+	span := Span{File: r.file, Start: start, End: r.pos}
+	*r.comments = append(*r.comments, comment{text: r.raw.String(), span: span})
+	r.raw = nil
+}
+
+func (r *reader) dropComment(outermost bool) {
+	if !outermost {
+		return
+	}
+	r.raw = nil
+}
+
+func isNotNewline(chr rune) bool { return chr != '\n' }
+
+// skipHashComment skips a block or datum comment and reports whether there was one.
+// When the # does not start a comment, it is pushed back to be read as part of an atom.
+func (r *reader) skipHashComment() bool {
+	open := r.pos
+	r.accept()
+	switch r.peek() {
+	case '|':
+		r.accept()
+		r.skipBlockComment(open)
+		return true
+	case ';':
+		r.accept()
+		r.skipDatum(open)
+		return true
+	}
+	r.pushBack('#', open)
+	return false
+}
+
+func (r *reader) skipBlockComment(open Position) {
+	for depth := 1; depth > 0 && r.err == nil; {
+		switch r.accept() {
+		case '|':
+			if r.peek() == '#' {
+				r.accept()
+				depth--
+			}
+		case '#':
+			if r.peek() == '|' {
+				r.accept()
+				depth++
+			}
+		}
+	}
+	r.unclosed(open, "|#", "unclosed block comment")
+}
+
+func (r *reader) skipDatum(open Position) {
+	if datum, _ := r.parse(); Equal(datum, Tree{}) {
+		r.unclosed(open, "a form", "nothing to comment out")
+	}
+}
+
 func (r *reader) parseAtom() (Tree, error) {
+	start := r.pos
 	atom := r.readWhile(isAtom)
+	if atom == "." {
+		r.dot = start
+	}
 
 	if atom == "" {
 		return Tree{}, r.err
 	}
 
+	return atomTree(atom), r.err
+}
+
+// atomTree decides what kind of Tree the text of an atom stands for.
+// Anything that doesn't look like a number is a symbol.
+func atomTree(atom string) Tree {
 	if num, err := strconv.Atoi(atom); err == nil {
-		return Num(num), r.err
+		return Num(num)
+	}
+	if isInteger(atom) {
+		n, _ := new(big.Int).SetString(atom, 10)
+		return BigInt(n)
+	}
+	if num, den, ok := strings.Cut(atom, "/"); ok && isInteger(num) && isDigits(den) {
+		if r, ok := new(big.Rat).SetString(atom); ok {
+			return Rat(r)
+		}
+	}
+	if f, ok := parseFloat(atom); ok {
+		return Float(f)
+	}
+	return Sym(atom)
+}
+
+// isInteger checks if s is a sequence of decimal digits with an optional sign.
+func isInteger(s string) bool {
+	return isDigits(trimSign(s))
+}
+
+func trimSign(s string) string {
+	if strings.HasPrefix(s, "+") || strings.HasPrefix(s, "-") {
+		return s[1:]
+	}
+	return s
+}
+
+func isDigits(s string) bool {
+	for _, chr := range s {
+		if chr < '0' || chr > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Floats that are not finite have no decimal representation.
+// They use the same syntax as in Scheme.
+const (
+	posInfSyntax = "+inf.0"
+	negInfSyntax = "-inf.0"
+	nanSyntax    = "+nan.0"
+)
+
+// parseFloat accepts decimal floats that have a fractional part, an exponent or both.
+// Anything else is either an integer or not a number at all.
+func parseFloat(s string) (float64, bool) {
+	switch s {
+	case posInfSyntax:
+		return math.Inf(1), true
+	case negInfSyntax:
+		return math.Inf(-1), true
+	case nanSyntax:
+		return math.NaN(), true
+	}
+	if !isDecimalFloat(s) {
+		return 0, false
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, false
+	}
+	return f, true
+}
+
+func isDecimalFloat(s string) bool {
+	mantissa, exponent, hasExponent := strings.Cut(strings.ToLower(trimSign(s)), "e")
+	whole, frac, hasPoint := strings.Cut(mantissa, ".")
+	if !hasPoint && !hasExponent {
+		return false
 	}
+	if whole+frac == "" || (whole != "" && !isDigits(whole)) || (frac != "" && !isDigits(frac)) {
+		return false
+	}
+	return !hasExponent || isInteger(exponent)
+}
 
-	return Sym(atom), r.err
+// formatFloat writes a float so that reading it back produces the same float.
+// The result always has a fractional part or an exponent, so it does not read back as an integer.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return posInfSyntax
+	case math.IsInf(f, -1):
+		return negInfSyntax
+	case math.IsNaN(f):
+		return nanSyntax
+	}
+	s := strconv.FormatFloat(f, 'g', -1, 64)
+	if !strings.ContainsAny(s, ".e") {
+		s += ".0"
+	}
+	return s
 }
 
 func isAtom(chr rune) bool {
-	return !unicode.IsSpace(chr) && chr != ')'
+	return !unicode.IsSpace(chr) && !strings.ContainsRune(`()";`, chr)
+}
+
+func (r *reader) parseQuotedSymbol() (Tree, error) {
+	name := r.parseQuoted('|', "unclosed symbol")
+	return r.resultIfNoError(Sym(name))
+}
+
+func (r *reader) parseString() (Tree, error) {
+	text := r.parseQuoted('"', "unclosed string")
+	return r.resultIfNoError(Str(text))
+}
+
+// parseQuoted reads text between two delim runes, interpreting escape sequences.
+func (r *reader) parseQuoted(delim rune, problem string) string {
+	open := r.pos
+	r.accept()
+	var buf bytes.Buffer
+	for chr := r.accept(); r.err == nil && chr != delim; chr = r.accept() {
+		if chr == '\\' {
+			r.parseEscape(&buf)
+		} else {
+			buf.WriteRune(chr)
+		}
+	}
+	r.unclosed(open, string(delim), problem)
+	return buf.String()
+}
+
+// simpleEscapes maps the rune after a backslash to the rune the escape stands for.
+var simpleEscapes = map[rune]rune{
+	'a': '\a', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t', 'v': '\v',
+	'\\': '\\', '"': '"', '|': '|',
+}
+
+func (r *reader) parseEscape(dst *bytes.Buffer) {
+	pos := r.pos
+	chr := r.accept()
+	if r.err != nil {
+		return
+	}
+	if unescaped, ok := simpleEscapes[chr]; ok {
+		dst.WriteRune(unescaped)
+		return
+	}
+	switch chr {
+	case 'x':
+		r.parseByteEscape(dst)
+	case 'u':
+		r.parseRuneEscape(dst)
+	default:
+		r.fail(pos, chr, "an escape sequence", "unknown escape sequence")
+	}
+}
+
+func (r *reader) parseByteEscape(dst *bytes.Buffer) {
+	var b byte
+	for i := 0; i < 2; i++ {
+		b = b<<4 | byte(r.parseHexDigit("invalid byte escape"))
+	}
+	dst.WriteByte(b)
+}
+
+// The longest hexadecimal number that can be a valid Unicode code point.
+const maxRuneEscapeDigits = 6
+
+func (r *reader) parseRuneEscape(dst *bytes.Buffer) {
+	pos := r.pos
+	if chr := r.accept(); chr != '{' {
+		r.fail(pos, chr, "{", "invalid code point escape")
+		return
+	}
+	var code rune
+	digits := 0
+	for ; r.err == nil && r.peek() != '}' && digits < maxRuneEscapeDigits; digits++ {
+		code = code<<4 | r.parseHexDigit("invalid code point escape")
+	}
+	pos = r.pos
+	switch chr := r.accept(); {
+	case chr != '}':
+		r.fail(pos, chr, "}", "invalid code point escape")
+	case digits == 0:
+		r.fail(pos, chr, "hexadecimal digit", "invalid code point escape")
+	case !utf8.ValidRune(code):
+		r.fail(pos, chr, "a valid code point", "invalid code point escape")
+	}
+	dst.WriteRune(code)
+}
+
+func (r *reader) parseHexDigit(problem string) rune {
+	pos := r.pos
+	chr := r.accept()
+	digit, err := strconv.ParseUint(string(chr), 16, 8)
+	if err != nil {
+		r.fail(pos, chr, "hexadecimal digit", problem)
+	}
+	return rune(digit)
+}
+
+// setErr records err, unless an earlier error has already been recorded.
+func (r *reader) setErr(err error) {
+	if r.err != nil {
+		return
+	}
+	r.err = err
 }
 
 func (r *reader) readWhile(f func(rune) bool) string {
@@ -103,7 +513,7 @@ func (r *reader) skipWhile(f func(rune) bool) {
 
 func (r *reader) takeWhile(f func(rune) bool, dst io.Writer) {
 	var buf [4]byte
-	for chr := r.peek(); r.err == nil && f(chr); chr = r.peek() {
+	for chr := r.peek(); r.more() && f(chr); chr = r.peek() {
 		r.accept()
 		runeLen := utf8.EncodeRune(buf[:], chr)
 		dst.Write(buf[:runeLen])
@@ -119,21 +529,61 @@ func (r *reader) peek() rune {
 func (r *reader) accept() rune { return r.read() }
 
 func (r *reader) read() rune {
+	r.prevPos = r.pos
+	r.wasPended = r.hasPending
+	r.rawAdded = 0
+	if r.hasPending {
+		r.hasPending = false
+		r.pos = r.pos.advance(r.pending, utf8.RuneLen(r.pending))
+		r.keepRaw(r.pending)
+		return r.pending
+	}
 	var chr rune
 	if r.err != nil {
 		return chr
 	}
-	chr, _, r.err = r.src.ReadRune()
+	chr, size, err := r.src.ReadRune()
+	if err != nil {
+		r.err = err
+		return chr
+	}
+	r.pos = r.pos.advance(chr, size)
+	r.keepRaw(chr)
 	return chr
 }
 
+func (r *reader) keepRaw(chr rune) {
+	if r.raw == nil {
+		return
+	}
+	r.rawAdded, _ = r.raw.WriteRune(chr)
+}
+
 func (r *reader) unread() {
+	r.pos = r.prevPos
+	if r.raw != nil {
+		r.raw.Truncate(r.raw.Len() - r.rawAdded)
+		r.rawAdded = 0
+	}
+	if r.wasPended {
+		r.hasPending = true
+		return
+	}
 	if r.err != nil {
 		return
 	}
 	r.err = r.src.UnreadRune()
 }
 
+// pushBack makes chr, found at pos, the next rune read.
+func (r *reader) pushBack(chr rune, pos Position) {
+	r.pending, r.hasPending = chr, true
+	r.pos = pos
+}
+
+// more checks whether there might be anything left to read.
+func (r *reader) more() bool { return r.hasPending || r.err == nil }
+
 // WriteSexpr writes the s-expression form of t into dst.
 func WriteSexpr(dst io.Writer, t Tree) (n int, err error) {
 	w := writer{dst: dst}
@@ -156,24 +606,51 @@ type writer struct {
 
 func (w *writer) WriteTree(t Tree) {
 	t.IfInvalid(func() { w.Write("<invalid symtree>") })
-	t.IfSymbol(func(s string) { w.Write(s) })
+	t.IfSymbol(w.WriteSymbol)
 	t.IfNumber(func(n int) { w.Write(n) })
+	t.IfFloat(func(f float64) { w.Write(formatFloat(f)) })
+	t.IfBigInt(func(n *big.Int) { w.Write(n.String()) })
+	t.IfRat(func(r *big.Rat) { w.Write(r.String()) })
+	t.IfString(func(s string) { w.Write(quote(s, '"')) })
 	t.IfList(w.WriteList)
 }
 
-func (w *writer) WriteList(list List) {
-	w.Write("(")
-	for i := 0; i < list.Len(); i++ {
-		w.WriteElement(list, i)
+func (w *writer) WriteSymbol(name string) {
+	if !isPlainSymbol(name) {
+		name = quote(name, '|')
 	}
-	w.Write(")")
+	w.Write(name)
 }
 
-func (w *writer) WriteElement(list List, i int) {
-	w.WriteTree(list.At(i))
-	if i+1 < list.Len() {
-		w.Write(" ")
+// isPlainSymbol checks if a symbol reads back the same when written without quoting.
+func isPlainSymbol(name string) bool {
+	if name == "" || !utf8.ValidString(name) || strings.HasPrefix(name, "|") {
+		return false
+	}
+	if name == "." || strings.HasPrefix(name, "#|") || strings.HasPrefix(name, "#;") {
+		return false
 	}
+	for _, chr := range name {
+		if !isAtom(chr) || !unicode.IsPrint(chr) {
+			return false
+		}
+	}
+	return Equal(Sym(name), atomTree(name))
+}
+
+func (w *writer) WriteList(list List) {
+	w.Write("(")
+	for i, elem := range list.All() {
+		if i > 0 {
+			w.Write(" ")
+		}
+		w.WriteTree(elem)
+	}
+	if tail, ok := list.Tail(); ok {
+		w.Write(" . ")
+		w.WriteTree(tail)
+	}
+	w.Write(")")
 }
 
 func (w *writer) Write(v interface{}) {
@@ -184,3 +661,32 @@ func (w *writer) Write(v interface{}) {
 	n, w.err = fmt.Fprint(w.dst, v)
 	w.n += n
 }
+
+// quote surrounds text with delim runes, escaping whatever would not read back the same.
+func quote(text string, delim rune) string {
+	var buf strings.Builder
+	buf.WriteRune(delim)
+	for i := 0; i < len(text); {
+		chr, size := utf8.DecodeRuneInString(text[i:])
+		switch {
+		case chr == utf8.RuneError && size == 1:
+			fmt.Fprintf(&buf, "\\x%02X", text[i])
+		case chr == delim || chr == '\\':
+			buf.WriteRune('\\')
+			buf.WriteRune(chr)
+		case chr == '\n':
+			buf.WriteString("\\n")
+		case chr == '\t':
+			buf.WriteString("\\t")
+		case chr == '\r':
+			buf.WriteString("\\r")
+		case !unicode.IsPrint(chr):
+			fmt.Fprintf(&buf, "\\u{%X}", chr)
+		default:
+			buf.WriteRune(chr)
+		}
+		i += size
+	}
+	buf.WriteRune(delim)
+	return buf.String()
+}
@@ -7,6 +7,8 @@ package symtree
 import (
 	"bytes"
 	"io"
+	"math"
+	"math/big"
 	"strings"
 	"testing"
 
@@ -28,6 +30,10 @@ func TestReadSexpr(t *testing.T) {
 		assert(t.Errorf, io.EOF == cause, "expected error cause %q, got %q", io.EOF, cause)
 	}
 
+	someError := func(t *testing.T, err error) {
+		assert(t.Errorf, nil != err, "expected an error")
+	}
+
 	causeUnexpectedEOF := func(t *testing.T, err error) {
 		cause := errors.Cause(err)
 		assert(t.Errorf, io.ErrUnexpectedEOF == cause, "expected error cause %q, got %q", io.ErrUnexpectedEOF, cause)
@@ -53,6 +59,64 @@ func TestReadSexpr(t *testing.T) {
 		"negativeNumber":   {"-9", Num(-9), causeEOF},
 		"listWithNumbers":  {"(+ 13 x)", Lst(Sym("+"), Num(13), Sym("x")), noError},
 		"initSpaceSkipped": {"   +", Sym("+"), causeEOF},
+		"float":            {"3.14", Float(3.14), causeEOF},
+		"floatNoWhole":     {"-.5", Float(-0.5), causeEOF},
+		"floatNoFraction":  {"5.", Float(5), causeEOF},
+		"floatExponent":    {"1e3", Float(1000), causeEOF},
+		"floatBoth":        {"2.5E-1", Float(0.25), causeEOF},
+		"floatPosInf":      {"+inf.0", Float(math.Inf(1)), causeEOF},
+		"floatNegInf":      {"-inf.0", Float(math.Inf(-1)), causeEOF},
+		"floatNaN":         {"+nan.0", Float(math.NaN()), causeEOF},
+		"bigInt":           {"123456789012345678901234567890", BigInt(hugeInt()), causeEOF},
+		"rational":         {"1/3", Rat(big.NewRat(1, 3)), causeEOF},
+		"negativeRational": {"-2/4", Rat(big.NewRat(-1, 2)), causeEOF},
+		"wholeRational":    {"4/2", Num(2), causeEOF},
+		"zeroDenominator":  {"1/0", Sym("1/0"), causeEOF},
+		"loneDot":          {".", Sym("."), causeEOF},
+		"loneSign":         {"-", Sym("-"), causeEOF},
+		"noMantissa":       {"e5", Sym("e5"), causeEOF},
+		"inf":              {"inf", Sym("inf"), causeEOF},
+		"listWithFloats":   {"(* 2.0 1/2)", Lst(Sym("*"), Float(2), Rat(big.NewRat(1, 2))), noError},
+		"emptyString":      {`""`, Str(""), noError},
+		"string":           {`"hello world"`, Str("hello world"), noError},
+		"stringEscapes":    {`"a\n\t\"b\"\\"`, Str("a\n\t\"b\"\\"), noError},
+		"stringByteEscape": {`"\x41\xff"`, Str("A\xff"), noError},
+		"stringRuneEscape": {`"\u{3bb}\u{1F600}"`, Str("λ😀"), noError},
+		"unclosedString":   {`"abc`, Tree{}, causeUnexpectedEOF},
+		"unclosedEscape":   {`"abc\`, Tree{}, causeUnexpectedEOF},
+		"unknownEscape":    {`"\q"`, Tree{}, someError},
+		"badRuneEscape":    {`"\u{110000}"`, Tree{}, someError},
+		"symbolThenString": {`abc"def"`, Sym("abc"), noError},
+		"listWithStrings":  {`(concat "a b" "c")`, Lst(Sym("concat"), Str("a b"), Str("c")), noError},
+		"quotedSymbol":     {"|a b|", Sym("a b"), noError},
+		"emptySymbol":      {"||", Sym(""), noError},
+		"digitSymbol":      {"|42|", Sym("42"), noError},
+		"barInSymbol":      {`|a\|b|`, Sym("a|b"), noError},
+		"unclosedSymbol":   {"|a b", Tree{}, causeUnexpectedEOF},
+		"symbolThenList":   {"a(b)", Sym("a"), noError},
+		"lineComment":      {"; a comment\nx", Sym("x"), causeEOF},
+		"commentOnly":      {"; a comment", Tree{}, causeEOF},
+		"commentInList":    {"(a ; a comment\n b)", Lst(Sym("a"), Sym("b")), noError},
+		"commentEndsAtom":  {"(a;comment\n)", Lst(Sym("a")), noError},
+		"blockComment":     {"#| a\ncomment |# x", Sym("x"), causeEOF},
+		"nestedBlock":      {"#| a #| nested |# comment |# x", Sym("x"), causeEOF},
+		"blockInList":      {"(a #|b|# c)", Lst(Sym("a"), Sym("c")), noError},
+		"unclosedBlock":    {"#| a #| b |#", Tree{}, causeUnexpectedEOF},
+		"datumComment":     {"#;(a (b c)) x", Sym("x"), causeEOF},
+		"datumInList":      {"(a #; b c)", Lst(Sym("a"), Sym("c")), noError},
+		"nestedDatum":      {"(#;#;a b c)", Lst(Sym("c")), noError},
+		"missingDatum":     {"(a #;", Tree{}, causeUnexpectedEOF},
+		"hashSymbol":       {"#t", Sym("#t"), causeEOF},
+		"loneHash":         {"#", Sym("#"), causeEOF},
+		"hashInList":       {"(# #x)", Lst(Sym("#"), Sym("#x")), noError},
+		"dottedPair":       {"(a . b)", DottedLst([]Tree{Sym("a")}, Sym("b")), noError},
+		"improperList":     {"(1 2 . rest)", DottedLst([]Tree{Num(1), Num(2)}, Sym("rest")), noError},
+		"dotBeforeList":    {"(a . (b c))", Lst(Sym("a"), Sym("b"), Sym("c")), noError},
+		"dotBeforeEmpty":   {"(a . ())", Lst(Sym("a")), noError},
+		"dotInSymbol":      {"(a .b c.)", Lst(Sym("a"), Sym(".b"), Sym("c.")), noError},
+		"quotedDot":        {"(a |.| b)", Lst(Sym("a"), Sym("."), Sym("b")), noError},
+		"commentedDot":     {"(a #;. b)", Lst(Sym("a"), Sym("b")), noError},
+		"unclosedTail":     {"(a . b", Tree{}, causeUnexpectedEOF},
 	}
 
 	for name, kase := range cases {
@@ -66,6 +130,46 @@ func TestReadSexpr(t *testing.T) {
 	}
 }
 
+func TestReadSexprSyntaxErrors(t *testing.T) {
+	type testcase struct {
+		input    string
+		pos      Position
+		found    rune
+		expected string
+	}
+	cases := map[string]testcase{
+		"unmatchedParen":    {"(a\n  (b c)", Position{Offset: 0, Line: 1, Column: 1}, -1, ")"},
+		"unmatchedInner":    {"(a\n  (b c", Position{Offset: 5, Line: 2, Column: 3}, -1, ")"},
+		"unclosedString":    {`(a "b c)`, Position{Offset: 3, Line: 1, Column: 4}, -1, `"`},
+		"unclosedSymbol":    {"(x |y", Position{Offset: 3, Line: 1, Column: 4}, -1, "|"},
+		"unclosedBlock":     {"#| #| |#", Position{Offset: 0, Line: 1, Column: 1}, -1, "|#"},
+		"strayParen":        {"  )", Position{Offset: 2, Line: 1, Column: 3}, ')', "a form"},
+		"unknownEscape":     {`"λ\q"`, Position{Offset: 4, Line: 1, Column: 4}, 'q', "an escape sequence"},
+		"badByteEscape":     {`"\x4g"`, Position{Offset: 4, Line: 1, Column: 5}, 'g', "hexadecimal digit"},
+		"runeEscapeNoBrace": {`"\u41"`, Position{Offset: 3, Line: 1, Column: 4}, '4', "{"},
+		"runeEscapeEmpty":   {`"\u{}"`, Position{Offset: 4, Line: 1, Column: 5}, '}', "hexadecimal digit"},
+		"runeEscapeLong":    {`"\u{1234567}"`, Position{Offset: 10, Line: 1, Column: 11}, '7', "}"},
+		"runeEscapeRange":   {`"\u{D800}"`, Position{Offset: 8, Line: 1, Column: 9}, '}', "a valid code point"},
+		"leadingDot":        {"(. a)", Position{Offset: 1, Line: 1, Column: 2}, '.', "a form"},
+		"missingTail":       {"(a . )", Position{Offset: 5, Line: 1, Column: 6}, ')', "a form"},
+		"twoDots":           {"(a . . b)", Position{Offset: 5, Line: 1, Column: 6}, '.', "a form"},
+		"twoTails":          {"(a . b c)", Position{Offset: 7, Line: 1, Column: 8}, 'c', ")"},
+	}
+
+	for name, kase := range cases {
+		t.Run(name, func(t *testing.T) {
+
+			_, err := ReadSexpr(strings.NewReader(kase.input))
+
+			syntaxErr, typeOK := err.(SyntaxError)
+			assert(t.Fatalf, typeOK, "error returned should implement SyntaxError, got %v", err)
+			assert(t.Errorf, kase.pos == syntaxErr.Position(), "expected position %#v, got %#v", kase.pos, syntaxErr.Position())
+			assert(t.Errorf, kase.found == syntaxErr.Found(), "expected to find %q, not %q", kase.found, syntaxErr.Found())
+			assert(t.Errorf, kase.expected == syntaxErr.Expected(), "expected to expect %q, not %q", kase.expected, syntaxErr.Expected())
+		})
+	}
+}
+
 func TestWriteSexpr(t *testing.T) {
 	type testcase struct {
 		tree     Tree
@@ -78,6 +182,33 @@ func TestWriteSexpr(t *testing.T) {
 		"emptyList":  {Lst(), "()"},
 		"flatList":   {Lst(Sym("+"), Num(13), Num(4)), "(+ 13 4)"},
 		"nestedList": {Lst(Lst()), "(())"},
+		"float":      {Float(3.14), "3.14"},
+		"wholeFloat": {Float(3), "3.0"},
+		"tinyFloat":  {Float(1e-7), "1e-07"},
+		"posInf":     {Float(math.Inf(1)), "+inf.0"},
+		"negInf":     {Float(math.Inf(-1)), "-inf.0"},
+		"nan":        {Float(math.NaN()), "+nan.0"},
+		"bigInt":     {BigInt(hugeInt()), "123456789012345678901234567890"},
+		"rational":   {Rat(big.NewRat(1, 3)), "1/3"},
+		"wholeRat":   {Rat(big.NewRat(4, 2)), "2"},
+		"string":     {Str("hello world"), `"hello world"`},
+		"escapes":    {Str("a\"b\\c\nd\u0007\xff"), `"a\"b\\c\nd\u{7}\xFF"`},
+		"unicode":    {Str("zażółć"), `"zażółć"`},
+		"plainSym":   {Sym("λ-x"), "λ-x"},
+		"spacedSym":  {Sym("a b"), "|a b|"},
+		"parenSym":   {Sym("("), "|(|"},
+		"emptySym":   {Sym(""), "||"},
+		"numberSym":  {Sym("42"), "|42|"},
+		"floatSym":   {Sym("+inf.0"), "|+inf.0|"},
+		"barSym":     {Sym("|x"), `|\|x|`},
+		"quoteSym":   {Sym(`a"b`), `|a"b|`},
+		"commentSym": {Sym("a;b"), "|a;b|"},
+		"blockSym":   {Sym("#|"), "|#\\||"},
+		"datumSym":   {Sym("#;x"), "|#;x|"},
+		"hashSym":    {Sym("#x"), "#x"},
+		"dotSym":     {Sym("."), "|.|"},
+		"dottedPair": {DottedLst([]Tree{Sym("a")}, Num(1)), "(a . 1)"},
+		"improper":   {DottedLst([]Tree{Lst(), Str("b")}, Sym("c")), `(() "b" . c)`},
 	}
 
 	for name, kase := range cases {
@@ -93,3 +224,54 @@ func TestWriteSexpr(t *testing.T) {
 		})
 	}
 }
+
+func TestNumbersSurviveWritingAndReadingBack(t *testing.T) {
+	numbers := []Tree{
+		Num(-7), Float(0.1), Float(1e300), Float(math.Copysign(0, -1)), Float(3), Float(math.Inf(-1)), Float(math.NaN()),
+		BigInt(hugeInt()), BigInt(new(big.Int).Neg(hugeInt())), Rat(big.NewRat(-5, 7)), Rat(big.NewRat(4, 2)),
+	}
+	for _, number := range numbers {
+		var b bytes.Buffer
+		WriteSexpr(&b, number)
+
+		tree, _ := ReadSexpr(strings.NewReader(b.String()))
+
+		assert(t.Errorf, Equal(number, tree), "%q read back as %v, expected %v", b.String(), tree, number)
+	}
+}
+
+func TestStringsSurviveWritingAndReadingBack(t *testing.T) {
+	texts := []string{"", "plain", "with space", "(parens)", `"quoted"`, "back\\slash", "tab\tnew\nline", "\x00\x7f\xfe", "ünïcødé 😀"}
+	for _, text := range texts {
+		var b bytes.Buffer
+		WriteSexpr(&b, Str(text))
+
+		tree, err := ReadSexpr(strings.NewReader(b.String()))
+
+		assert(t.Errorf, err == nil, "unexpected error reading %q: %s", b.String(), err)
+		assert(t.Errorf, Equal(Str(text), tree), "%q read back as %v, expected %v", b.String(), tree, Str(text))
+	}
+}
+
+func TestWriteSexprOutputReadsBackAsTheSameTree(t *testing.T) {
+	trees := map[string]Tree{
+		"symbol":       Sym("abba"),
+		"oddSymbols":   Lst(Sym(""), Sym("a b"), Sym("("), Sym(")"), Sym("|"), Sym("\\"), Sym(";"), Sym("#|"), Sym("#;"), Sym("#"), Sym("1/2"), Sym("-1e3"), Sym("\n"), Sym("\xff")),
+		"numbers":      Lst(Num(-3), Float(-0.5), BigInt(hugeInt()), Rat(big.NewRat(3, 4))),
+		"strings":      Lst(Str(""), Str("a|b"), Str("(")),
+		"nestedLists":  Lst(Lst(), Lst(Lst(Sym("x"))), Lst(Sym("+"), Num(1), Lst(Sym("*"), Num(2), Sym("y")))),
+		"adjacentAtom": Lst(Str("a"), Sym("b"), Str("c")),
+		"improper":     Lst(Sym("."), DottedLst([]Tree{Sym(".")}, Sym(".")), DottedLst([]Tree{Num(1)}, DottedLst([]Tree{Num(2)}, Num(3)))),
+	}
+	for name, tree := range trees {
+		t.Run(name, func(t *testing.T) {
+			var b bytes.Buffer
+			WriteSexpr(&b, tree)
+
+			readBack, err := ReadSexpr(strings.NewReader(b.String()))
+
+			assert(t.Errorf, err == nil || err == io.EOF, "unexpected error reading %q: %s", b.String(), err)
+			assert(t.Errorf, Equal(tree, readBack), "%q read back as %v, expected %v", b.String(), readBack, tree)
+		})
+	}
+}
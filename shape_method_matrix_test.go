@@ -6,16 +6,16 @@ package symtree
 
 import (
 	"fmt"
+	"math/big"
 	"testing"
 )
 
-// Each Tree is one of four possibl "shapes": invalid, a symbol, a number or a list.
+// Each Tree is one of several possible "shapes": invalid, a symbol, one of the kinds of number, a string or a list.
 // No If* method works the same for all shapes.
 // Naturally, we want to test each method with each shape.
 //
-// Writing all 16 cases by hand would be error prone.
-// Adding a new shape later on would require writing 9 new tests.
-// More generally, given n shapes, adding a new one requires adding 2n - 1 tests.
+// Writing all n^2 cases by hand would be error prone.
+// Given n shapes, adding a new one requires adding 2n + 1 tests.
 //
 // Here's the trick to making the effort O(1).
 // The If* methods all take callbacks.
@@ -43,6 +43,22 @@ func TestTreeShapeMethodMatrix(t *testing.T) {
 			Num(13), "number", "IfNumber", func(t Tree, wasCalled *bool) {
 				t.IfNumber(func(_ int) { *wasCalled = true })
 			},
+		}, {
+			Float(1.5), "float", "IfFloat", func(t Tree, wasCalled *bool) {
+				t.IfFloat(func(_ float64) { *wasCalled = true })
+			},
+		}, {
+			BigInt(hugeInt()), "bigInt", "IfBigInt", func(t Tree, wasCalled *bool) {
+				t.IfBigInt(func(_ *big.Int) { *wasCalled = true })
+			},
+		}, {
+			Rat(big.NewRat(1, 3)), "rational", "IfRat", func(t Tree, wasCalled *bool) {
+				t.IfRat(func(_ *big.Rat) { *wasCalled = true })
+			},
+		}, {
+			Str("a string"), "string", "IfString", func(t Tree, wasCalled *bool) {
+				t.IfString(func(_ string) { *wasCalled = true })
+			},
 		}, {
 			Lst(), "list", "IfList", func(t Tree, wasCalled *bool) {
 				t.IfList(func(_ List) { *wasCalled = true })
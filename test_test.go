@@ -4,8 +4,16 @@
 
 package symtree
 
+import "math/big"
+
 func assert(onErr func(string, ...interface{}), cond bool, format string, a ...interface{}) {
 	if !cond {
 		onErr(format, a...)
 	}
 }
+
+// hugeInt returns a number too large to fit in an int.
+func hugeInt() *big.Int {
+	n, _ := new(big.Int).SetString("123456789012345678901234567890", 10)
+	return n
+}
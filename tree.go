@@ -5,12 +5,21 @@
 // Package symtree implements immutable, s-expression-like trees.
 package symtree
 
+import (
+	"math"
+	"math/big"
+	"slices"
+)
+
 // A Tree is either
 //
-//     - invalid
-//     - a symbol
-//     - a number
-//     - a list of other Trees
+//   - invalid
+//   - a symbol
+//   - a number
+//   - a string
+//   - a list of other Trees, possibly improper, with a tail after the elements
+//
+// A number is either an int, a float64, a big integer too large for an int or a rational that is not an integer.
 //
 // Trees are immutable.
 //
@@ -21,11 +30,23 @@ package symtree
 type Tree struct {
 	// A symbolic tree consists of a tag and one field for each valid shape.
 	// The tag tells us which shape the value has.
+	// Strings keep their text in the symbol field, so that Trees stay small.
+	//
+	// The span is only there for trees read from text with spans enabled.
+	// It is nil otherwise.
+	//
+	// The node is only there for trees built by a Factory.
+	// Such trees are equal exactly when they have the same node.
 
 	tag    symTreeTag
 	symbol string
 	number int
+	float  float64
+	bigInt *big.Int
+	rat    *big.Rat
 	list   List
+	span   *Span
+	node   *internNode
 }
 
 // Sym creates a Tree that calls the callback passed to IfSymbol.
@@ -38,11 +59,43 @@ func Num(n int) Tree {
 	return Tree{tag: symTreeNumber, number: n}
 }
 
+// Float creates a Tree that calls the callback passed to IfFloat.
+func Float(f float64) Tree {
+	return Tree{tag: symTreeFloat, float: f}
+}
+
+// BigInt creates a Tree that calls the callback passed to IfBigInt.
+// If n fits in an int, the result is the same as that of Num instead.
+// That way each integer has exactly one representation.
+//
+// The Tree holds a copy of n, so changing n later does not affect it.
+func BigInt(n *big.Int) Tree {
+	if n.IsInt64() && n.Int64() >= math.MinInt && n.Int64() <= math.MaxInt {
+		return Num(int(n.Int64()))
+	}
+	return Tree{tag: symTreeBigInt, bigInt: new(big.Int).Set(n)}
+}
+
+// Rat creates a Tree that calls the callback passed to IfRat.
+// If r is an integer, the result is the same as that of BigInt instead,
+// so that 4/2 is the same Tree as 2.
+//
+// The Tree holds a copy of r, so changing r later does not affect it.
+func Rat(r *big.Rat) Tree {
+	if r.IsInt() {
+		return BigInt(r.Num())
+	}
+	return Tree{tag: symTreeRat, rat: new(big.Rat).Set(r)}
+}
+
+// Str creates a Tree that calls the callback passed to IfString.
+func Str(text string) Tree {
+	return Tree{tag: symTreeString, symbol: text}
+}
+
 // Lst creates a Tree that calls the callback passed to IfList.
 func Lst(elems ...Tree) Tree {
-	l := List{elements: make([]Tree, len(elems))}
-	copy(l.elements, elems)
-	return Tree{tag: symTreeList, list: l}
+	return Tree{tag: symTreeList, list: listOf(slices.Clone(elems))}
 }
 
 // IfInvalid calls f if the receiver is not a valid Tree.
@@ -74,6 +127,42 @@ func (tree Tree) IfNumber(f func(int)) {
 	f(tree.number)
 }
 
+// IfFloat calls f if the receiver is a floating-point number Tree.
+// The argument passed in is the value of the number.
+func (tree Tree) IfFloat(f func(float64)) {
+	if tree.tag != symTreeFloat {
+		return
+	}
+	f(tree.float)
+}
+
+// IfBigInt calls f if the receiver is a big integer Tree.
+// The argument passed in is a copy of the number and can be modified freely.
+func (tree Tree) IfBigInt(f func(*big.Int)) {
+	if tree.tag != symTreeBigInt {
+		return
+	}
+	f(new(big.Int).Set(tree.bigInt))
+}
+
+// IfRat calls f if the receiver is a rational number Tree.
+// The argument passed in is a copy of the number and can be modified freely.
+func (tree Tree) IfRat(f func(*big.Rat)) {
+	if tree.tag != symTreeRat {
+		return
+	}
+	f(new(big.Rat).Set(tree.rat))
+}
+
+// IfString calls f if the receiver is a string Tree.
+// The argument passed in is the text of the string.
+func (tree Tree) IfString(f func(string)) {
+	if tree.tag != symTreeString {
+		return
+	}
+	f(tree.symbol)
+}
+
 // IfList calls f if the receiver is a list Tree.
 // The argument passed in is the List containing all the children of the Tree.
 func (tree Tree) IfList(f func(List)) {
@@ -83,27 +172,29 @@ func (tree Tree) IfList(f func(List)) {
 	f(tree.list)
 }
 
-// A List is an immutable sequence of Trees.
-type List struct {
-	len      int
-	elements []Tree
-}
-
-// Len returns the number of elements in the List.
-func (l List) Len() int { return len(l.elements) }
-
-// At looks up the i-th element of the List.
-// If i is out of bounds, then the returned tree is invalid.
-func (l List) At(i int) Tree {
-	if i < 0 || i >= len(l.elements) {
-		return Tree{}
+// Span returns the stretch of source text the Tree was read from.
+// The second result is false when the Tree was not read with spans enabled.
+//
+// Spans are ignored when comparing trees.
+func (tree Tree) Span() (Span, bool) {
+	if tree.span == nil {
+		return Span{}, false
 	}
-	return l.elements[i]
+	return *tree.span, true
 }
 
 // Equal compares two Trees for structural equality.
 // The == operator doesn't work on Trees.
+//
+// Numbers of different kinds are never equal, so Num(1) is not equal to Float(1).
+// Rationals that are integers are built as integers, though, so Rat(big.NewRat(2, 1)) is equal to Num(2).
+// All NaN floats are equal to each other.
+//
+// Trees built by the same Factory are compared in constant time.
 func Equal(a, b Tree) bool {
+	if a.node != nil && b.node != nil && a.node.factory == b.node.factory {
+		return a.node == b.node
+	}
 	if a.tag == symTreeInvalid && b.tag == symTreeInvalid {
 		return true
 	}
@@ -113,18 +204,39 @@ func Equal(a, b Tree) bool {
 	if a.tag == symTreeNumber && b.tag == symTreeNumber && a.number == b.number {
 		return true
 	}
+	if a.tag == symTreeFloat && b.tag == symTreeFloat && equalFloats(a.float, b.float) {
+		return true
+	}
+	if a.tag == symTreeBigInt && b.tag == symTreeBigInt && a.bigInt.Cmp(b.bigInt) == 0 {
+		return true
+	}
+	if a.tag == symTreeRat && b.tag == symTreeRat && a.rat.Cmp(b.rat) == 0 {
+		return true
+	}
+	if a.tag == symTreeString && b.tag == symTreeString && a.symbol == b.symbol {
+		return true
+	}
 	if a.tag == symTreeList && b.tag == symTreeList {
 		return equalLists(a.list, b.list)
 	}
 	return false
 }
 
+func equalFloats(a, b float64) bool {
+	return a == b || math.IsNaN(a) && math.IsNaN(b)
+}
+
 func equalLists(a, b List) bool {
-	eq := a.Len() == b.Len()
-	for i := 0; i < a.Len(); i++ {
-		eq = eq && Equal(a.At(i), b.At(i))
+	if a.Len() != b.Len() || a.IsProper() != b.IsProper() {
+		return false
+	}
+	as, bs := a.cursor(), b.cursor()
+	for x, ok := as.next(); ok; x, ok = as.next() {
+		if y, _ := bs.next(); !Equal(x, y) {
+			return false
+		}
 	}
-	return eq
+	return true
 }
 
 type symTreeTag int
@@ -134,4 +246,8 @@ const (
 	symTreeSymbol
 	symTreeNumber
 	symTreeList
+	symTreeFloat
+	symTreeBigInt
+	symTreeRat
+	symTreeString
 )
@@ -6,6 +6,8 @@ package symtree
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"testing"
 )
 
@@ -27,14 +29,118 @@ func TestNumberCallsCallbackWithRighInt(t *testing.T) {
 	})
 }
 
+func TestStringCallsCallbackWithRightText(t *testing.T) {
+	text := "some text"
+	tree := Str(text)
+
+	called := false
+	tree.IfString(func(textPassedIn string) {
+		called = true
+		assert(t.Errorf, text == textPassedIn, "expected %q, got %q", text, textPassedIn)
+	})
+	assert(t.Errorf, called, "the callback should have been called")
+}
+
+func TestFloatCallsCallbackWithRightFloat(t *testing.T) {
+	number := 2.5
+	tree := Float(number)
+
+	called := false
+	tree.IfFloat(func(numberPassedIn float64) {
+		called = true
+		assert(t.Errorf, number == numberPassedIn, "expected %g, got %g", number, numberPassedIn)
+	})
+	assert(t.Errorf, called, "the callback should have been called")
+}
+
+func TestBigIntCallsCallbackWithRightNumber(t *testing.T) {
+	number := hugeInt()
+	tree := BigInt(number)
+
+	called := false
+	tree.IfBigInt(func(numberPassedIn *big.Int) {
+		called = true
+		assert(t.Errorf, number.Cmp(numberPassedIn) == 0, "expected %s, got %s", number, numberPassedIn)
+	})
+	assert(t.Errorf, called, "the callback should have been called")
+}
+
+func TestBigIntThatFitsAnIntIsANumber(t *testing.T) {
+	tree := BigInt(big.NewInt(13))
+
+	assert(t.Errorf, Equal(Num(13), tree), "expected %v, got %v", Num(13), tree)
+}
+
+func TestBigIntIsNotAffectedByChangesToItsArgument(t *testing.T) {
+	number := hugeInt()
+	tree := BigInt(number)
+
+	number.SetInt64(1)
+	tree.IfBigInt(func(n *big.Int) { n.SetInt64(2) })
+
+	assert(t.Errorf, Equal(BigInt(hugeInt()), tree), "expected %v, got %v", BigInt(hugeInt()), tree)
+}
+
+func TestRatCallsCallbackWithRightNumber(t *testing.T) {
+	number := big.NewRat(1, 3)
+	tree := Rat(number)
+
+	called := false
+	tree.IfRat(func(numberPassedIn *big.Rat) {
+		called = true
+		assert(t.Errorf, number.Cmp(numberPassedIn) == 0, "expected %s, got %s", number, numberPassedIn)
+	})
+	assert(t.Errorf, called, "the callback should have been called")
+}
+
+func TestNaNIsEqualToItself(t *testing.T) {
+	tree := Float(math.NaN())
+
+	assert(t.Errorf, Equal(tree, tree), "tree %v should be equal to itself", tree)
+}
+
+func TestNumbersOfDifferentKindsAreNotEqual(t *testing.T) {
+	numbers := []Tree{Num(1), Float(1), Float(0.5), Rat(big.NewRat(1, 2))}
+	for i, left := range numbers {
+		for j, right := range numbers {
+			if i == j {
+				continue
+			}
+			assert(t.Errorf, !Equal(left, right), "%v should not equal %v", left, right)
+		}
+	}
+}
+
+func TestRationalsThatAreIntegersAreIntegers(t *testing.T) {
+	cases := map[string]struct {
+		rat      *big.Rat
+		expected Tree
+	}{
+		"whole":    {big.NewRat(4, 2), Num(2)},
+		"negative": {big.NewRat(-3, 1), Num(-3)},
+		"zero":     {big.NewRat(0, 5), Num(0)},
+		"huge":     {new(big.Rat).SetInt(hugeInt()), BigInt(hugeInt())},
+	}
+	for name, c := range cases {
+		actual := Rat(c.rat)
+		assert(t.Errorf, Equal(actual, c.expected), "%s: expected %v, got %v", name, c.expected, actual)
+		assert(t.Errorf, Hash(actual) == Hash(c.expected), "%s: expected %v to hash the same as %v", name, actual, c.expected)
+	}
+}
+
 func TestTreesAreEqualToThemselves(t *testing.T) {
 	trees := map[string]Tree{
 		"invalid":    Tree{},
 		"symbol":     Sym("+"),
 		"number":     Num(13),
+		"float":      Float(13.5),
+		"bigInt":     BigInt(hugeInt()),
+		"rational":   Rat(big.NewRat(1, 3)),
+		"string":     Str("+"),
 		"emptyList":  Lst(),
 		"flatList":   Lst(Sym("sin"), Num(13)),
 		"nestedList": Lst(Lst()),
+		"improper":   DottedLst([]Tree{Sym("sin")}, Num(13)),
 	}
 	for caseName, tree := range trees {
 		t.Run(caseName, func(t *testing.T) {
@@ -48,9 +154,15 @@ func TestDifferentTreesAreNotEqual(t *testing.T) {
 		"invalid":    Tree{},
 		"symbol":     Sym("+"),
 		"number":     Num(13),
+		"float":      Float(13.5),
+		"bigInt":     BigInt(hugeInt()),
+		"rational":   Rat(big.NewRat(1, 3)),
+		"string":     Str("+"),
 		"emptyList":  Lst(),
 		"flatList":   Lst(Sym("sin"), Num(13)),
 		"nestedList": Lst(Lst()),
+		"dottedPair": DottedLst([]Tree{Sym("sin")}, Num(13)),
+		"improper":   DottedLst([]Tree{Sym("sin"), Num(13)}, Num(13)),
 	}
 	for leftName, left := range trees {
 		for rightName, right := range trees {
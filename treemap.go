@@ -0,0 +1,133 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+// A TreeMap maps Trees to values of type V.
+// Keys are compared with Equal, so a TreeMap works where a Go map of Trees wouldn't.
+//
+// The zero value is an empty map, ready to use.
+// A TreeMap must not be copied after first use.
+type TreeMap[V any] struct {
+	buckets map[uint64][]treeMapEntry[V]
+	len     int
+}
+
+type treeMapEntry[V any] struct {
+	key   Tree
+	value V
+}
+
+// Len returns the number of keys in the map.
+func (m *TreeMap[V]) Len() int { return m.len }
+
+// Get looks up the value stored under key.
+// The second result tells whether there was one.
+func (m *TreeMap[V]) Get(key Tree) (V, bool) {
+	bucket := m.buckets[Hash(key)]
+	if i := findEntry(bucket, key); i >= 0 {
+		return bucket[i].value, true
+	}
+	var zero V
+	return zero, false
+}
+
+// Set stores value under key, replacing any value stored under it before.
+func (m *TreeMap[V]) Set(key Tree, value V) {
+	if m.buckets == nil {
+		m.buckets = map[uint64][]treeMapEntry[V]{}
+	}
+	h := Hash(key)
+	bucket := m.buckets[h]
+	if i := findEntry(bucket, key); i >= 0 {
+		bucket[i].value = value
+		return
+	}
+	m.buckets[h] = append(bucket, treeMapEntry[V]{key: key, value: value})
+	m.len++
+}
+
+// Delete removes key from the map.
+// It reports whether the key was there.
+func (m *TreeMap[V]) Delete(key Tree) bool {
+	h := Hash(key)
+	bucket := m.buckets[h]
+	i := findEntry(bucket, key)
+	if i < 0 {
+		return false
+	}
+	bucket = append(bucket[:i:i], bucket[i+1:]...)
+	if len(bucket) == 0 {
+		delete(m.buckets, h)
+	} else {
+		m.buckets[h] = bucket
+	}
+	m.len--
+	return true
+}
+
+// Range calls f for each key and value in the map, in no particular order.
+// It stops early when f returns false.
+//
+// The map must not be modified while Range is running.
+func (m *TreeMap[V]) Range(f func(key Tree, value V) bool) {
+	for _, bucket := range m.buckets {
+		for _, entry := range bucket {
+			if !f(entry.key, entry.value) {
+				return
+			}
+		}
+	}
+}
+
+func findEntry[V any](bucket []treeMapEntry[V], key Tree) int {
+	for i, entry := range bucket {
+		if Equal(entry.key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// A TreeSet is a set of Trees.
+// Elements are compared with Equal.
+//
+// The zero value is an empty set, ready to use.
+// A TreeSet must not be copied after first use.
+type TreeSet struct {
+	m TreeMap[struct{}]
+}
+
+// Len returns the number of elements in the set.
+func (s *TreeSet) Len() int { return s.m.Len() }
+
+// Has checks whether t is in the set.
+func (s *TreeSet) Has(t Tree) bool {
+	_, ok := s.m.Get(t)
+	return ok
+}
+
+// Add puts t in the set.
+// It reports whether t was not in the set before.
+func (s *TreeSet) Add(t Tree) bool {
+	if s.Has(t) {
+		return false
+	}
+	s.m.Set(t, struct{}{})
+	return true
+}
+
+// Remove takes t out of the set.
+// It reports whether t was in the set.
+func (s *TreeSet) Remove(t Tree) bool {
+	return s.m.Delete(t)
+}
+
+// Range calls f for each element of the set, in no particular order.
+// It stops early when f returns false.
+//
+// The set must not be modified while Range is running.
+func (s *TreeSet) Range(f func(Tree) bool) {
+	s.m.Range(func(t Tree, _ struct{}) bool { return f(t) })
+}
@@ -0,0 +1,68 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import "testing"
+
+func TestTreeMapStoresValuesUnderEqualKeys(t *testing.T) {
+	var m TreeMap[int]
+
+	m.Set(Lst(Sym("+"), Num(1)), 1)
+	m.Set(Lst(Sym("+"), Num(2)), 2)
+	m.Set(Lst(Sym("+"), Num(1)), 3)
+
+	assert(t.Errorf, 2 == m.Len(), "expected %d keys, got %d", 2, m.Len())
+	value, ok := m.Get(Lst(Sym("+"), Num(1)))
+	assert(t.Errorf, ok && value == 3, "expected %d, got %d (found: %t)", 3, value, ok)
+	value, ok = m.Get(Lst(Sym("+"), Num(2)))
+	assert(t.Errorf, ok && value == 2, "expected %d, got %d (found: %t)", 2, value, ok)
+	_, ok = m.Get(Lst(Sym("+"), Num(3)))
+	assert(t.Errorf, !ok, "there should be no value under a key never set")
+}
+
+func TestTreeMapDelete(t *testing.T) {
+	var m TreeMap[string]
+	m.Set(Sym("a"), "a")
+	m.Set(Sym("b"), "b")
+
+	deleted := m.Delete(Sym("a"))
+	deletedAgain := m.Delete(Sym("a"))
+
+	assert(t.Errorf, deleted, "the key should have been deleted")
+	assert(t.Errorf, !deletedAgain, "the key should not be deleted twice")
+	assert(t.Errorf, 1 == m.Len(), "expected %d key, got %d", 1, m.Len())
+	_, ok := m.Get(Sym("a"))
+	assert(t.Errorf, !ok, "the deleted key should be gone")
+}
+
+func TestTreeMapRangeVisitsEachKeyOnce(t *testing.T) {
+	var m TreeMap[int]
+	for i := 0; i < 100; i++ {
+		m.Set(Lst(Sym("n"), Num(i)), i)
+	}
+
+	var seen TreeSet
+	m.Range(func(key Tree, value int) bool {
+		assert(t.Errorf, Equal(Lst(Sym("n"), Num(value)), key), "key %v has the value %d", key, value)
+		assert(t.Errorf, seen.Add(key), "key %v visited twice", key)
+		return true
+	})
+
+	assert(t.Errorf, 100 == seen.Len(), "expected %d keys visited, got %d", 100, seen.Len())
+}
+
+func TestTreeSetDeduplicates(t *testing.T) {
+	var s TreeSet
+
+	added := []bool{s.Add(Sym("x")), s.Add(Num(1)), s.Add(Sym("x")), s.Add(Lst(Sym("x"))), s.Add(Num(1))}
+
+	expected := []bool{true, true, false, true, false}
+	for i := range expected {
+		assert(t.Errorf, expected[i] == added[i], "add %d: expected %t, got %t", i, expected[i], added[i])
+	}
+	assert(t.Errorf, 3 == s.Len(), "expected %d elements, got %d", 3, s.Len())
+	assert(t.Errorf, s.Has(Lst(Sym("x"))), "the set should have %v", Lst(Sym("x")))
+	assert(t.Errorf, s.Remove(Num(1)) && !s.Has(Num(1)), "%v should be removed from the set", Num(1))
+}
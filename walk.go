@@ -0,0 +1,125 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import "math"
+
+// A WalkAction tells Walk how to go on after visiting a Tree.
+type WalkAction int
+
+const (
+	// Continue goes on with the walk as usual.
+	Continue WalkAction = iota
+	// SkipChildren doesn't visit the elements of the list just visited.
+	// It only makes a difference before visiting them.
+	SkipChildren
+	// Stop ends the walk straight away.
+	Stop
+)
+
+// Walk visits tree and all of its subtrees, depth first.
+// The tail of an improper list gets visited after its elements.
+// It calls pre on each subtree before visiting its elements and post after.
+// Either of them can be nil.
+//
+// Walk reports whether it went all the way through or was stopped.
+func Walk(tree Tree, pre, post func(Tree) WalkAction) bool {
+	return walk(tree, pre, post) != Stop
+}
+
+func walk(tree Tree, pre, post func(Tree) WalkAction) WalkAction {
+	action := Continue
+	if pre != nil {
+		action = pre(tree)
+	}
+	if action == Stop {
+		return Stop
+	}
+	if action != SkipChildren && tree.tag == symTreeList {
+		for _, elem := range tree.list.elemsAndTail() {
+			if walk(elem, pre, post) == Stop {
+				return Stop
+			}
+		}
+	}
+	if post != nil && post(tree) == Stop {
+		return Stop
+	}
+	return Continue
+}
+
+// Fold computes a value for a Tree out of the values for its subtrees.
+// It calls f on each subtree, bottom up.
+// For lists, the values computed for the elements are passed in, in order.
+// An improper list passes the value for its tail as well, after those for the elements.
+// For other Trees, there are none.
+func Fold[A any](tree Tree, f func(t Tree, elems []A) A) A {
+	if tree.tag != symTreeList {
+		return f(tree, nil)
+	}
+	elems := make([]A, 0, tree.list.extent())
+	for _, elem := range tree.list.elemsAndTail() {
+		elems = append(elems, Fold(elem, f))
+	}
+	return f(tree, elems)
+}
+
+// TransformUp rebuilds a Tree bottom up.
+// It calls f on each subtree after the elements of the subtree have been rebuilt,
+// and uses the result in place of the subtree.
+//
+// Subtrees that f leaves as they were are kept as they were, spans and all.
+func TransformUp(tree Tree, f func(Tree) Tree) Tree {
+	return f(transformElems(tree, func(elem Tree) Tree { return TransformUp(elem, f) }))
+}
+
+// TransformDown rebuilds a Tree top down.
+// It calls f on each subtree, uses the result in place of the subtree
+// and then goes on to rebuild the elements of the result.
+// So f must not keep making the Tree deeper, or TransformDown won't end.
+//
+// Subtrees that f leaves as they were are kept as they were, spans and all.
+func TransformDown(tree Tree, f func(Tree) Tree) Tree {
+	return transformElems(f(tree), func(elem Tree) Tree { return TransformDown(elem, f) })
+}
+
+// transformElems rebuilds a list by calling f on each of its elements and its tail, if any.
+// It returns tree itself when it's not a list or f leaves all the elements as they were.
+func transformElems(tree Tree, f func(Tree) Tree) Tree {
+	if tree.tag != symTreeList {
+		return tree
+	}
+	elems := tree.list.trees()
+	changed := false
+	for i, elem := range elems {
+		elems[i] = f(elem)
+		changed = changed || !identical(elem, elems[i])
+	}
+	tail, improper := tree.list.Tail()
+	if improper {
+		newTail := f(tail)
+		changed = changed || !identical(tail, newTail)
+		tail = newTail
+	}
+	if !changed {
+		return tree
+	}
+	return DottedLst(elems, tail)
+}
+
+// identical tells whether two Trees are not only Equal, but also the same in every other respect.
+// It is quick, as it doesn't look inside lists.
+func identical(a, b Tree) bool {
+	if a.tag != b.tag || a.span != b.span || a.node != b.node {
+		return false
+	}
+	switch a.tag {
+	case symTreeList:
+		return a.list.root == b.list.root && a.list.tail == b.list.tail
+	case symTreeFloat:
+		return math.Float64bits(a.float) == math.Float64bits(b.float)
+	}
+	return Equal(a, b)
+}
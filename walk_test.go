@@ -0,0 +1,195 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestWalk(t *testing.T) {
+	tree := Lst(Sym("a"), Lst(Sym("b"), Sym("c")), Sym("d"))
+	visit := func(visited *[]string, prefix string, action func(string) WalkAction) func(Tree) WalkAction {
+		return func(t Tree) WalkAction {
+			text := sexpr{t}.String()
+			*visited = append(*visited, prefix+text)
+			return action(text)
+		}
+	}
+	always := func(string) WalkAction { return Continue }
+	at := func(text string, action WalkAction) func(string) WalkAction {
+		return func(visited string) WalkAction {
+			if visited == text {
+				return action
+			}
+			return Continue
+		}
+	}
+	cases := map[string]struct {
+		pre, post func(string) WalkAction
+		visited   []string
+		completed bool
+	}{
+		"all": {
+			always, always,
+			[]string{"<(a (b c) d)", "<a", ">a", "<(b c)", "<b", ">b", "<c", ">c", ">(b c)", "<d", ">d", ">(a (b c) d)"},
+			true,
+		},
+		"skip": {
+			at("(b c)", SkipChildren), always,
+			[]string{"<(a (b c) d)", "<a", ">a", "<(b c)", ">(b c)", "<d", ">d", ">(a (b c) d)"},
+			true,
+		},
+		"stopBefore": {
+			at("b", Stop), always,
+			[]string{"<(a (b c) d)", "<a", ">a", "<(b c)", "<b"},
+			false,
+		},
+		"stopAfter": {
+			always, at("(b c)", Stop),
+			[]string{"<(a (b c) d)", "<a", ">a", "<(b c)", "<b", ">b", "<c", ">c", ">(b c)"},
+			false,
+		},
+	}
+	for name, c := range cases {
+		var visited []string
+		completed := Walk(tree, visit(&visited, "<", c.pre), visit(&visited, ">", c.post))
+
+		assert(t.Errorf, slices.Equal(visited, c.visited), "%s: expected to visit %v, visited %v", name, c.visited, visited)
+		assert(t.Errorf, completed == c.completed, "%s: expected Walk to return %t", name, c.completed)
+	}
+}
+
+func TestWalkWithoutPostVisitsInPreOrder(t *testing.T) {
+	var visited []string
+	Walk(Lst(Sym("a"), Lst(Sym("b"))), func(t Tree) WalkAction {
+		t.IfSymbol(func(name string) { visited = append(visited, name) })
+		return Continue
+	}, nil)
+
+	assert(t.Errorf, slices.Equal(visited, []string{"a", "b"}), "expected to visit a and b, visited %v", visited)
+}
+
+func TestFold(t *testing.T) {
+	tree := Lst(Sym("+"), Num(1), Lst(Sym("*"), Num(2), Num(3)))
+
+	depth := Fold(tree, func(_ Tree, elems []int) int {
+		deepest := 0
+		for _, d := range elems {
+			deepest = max(deepest, d)
+		}
+		return deepest + 1
+	})
+	sum := Fold(tree, func(t Tree, elems []int) int {
+		total := 0
+		t.IfNumber(func(n int) { total = n })
+		for _, n := range elems {
+			total += n
+		}
+		return total
+	})
+
+	assert(t.Errorf, depth == 3, "expected depth 3, got %d", depth)
+	assert(t.Errorf, sum == 6, "expected sum 6, got %d", sum)
+}
+
+func TestTransform(t *testing.T) {
+	// simplify replaces (+ n m) with the sum of n and m.
+	simplify := func(t Tree) Tree {
+		result := t
+		t.IfList(func(l List) {
+			var n, m *int
+			l.At(1).IfNumber(func(x int) { n = &x })
+			l.At(2).IfNumber(func(x int) { m = &x })
+			if Equal(l.At(0), Sym("+")) && l.Len() == 3 && n != nil && m != nil {
+				result = Num(*n + *m)
+			}
+		})
+		return result
+	}
+	tree := Lst(Sym("+"), Lst(Sym("+"), Num(1), Num(2)), Lst(Sym("+"), Num(3), Num(4)))
+
+	up := TransformUp(tree, simplify)
+	down := TransformDown(tree, simplify)
+
+	assert(t.Errorf, Equal(up, Num(10)), "expected TransformUp to give 10, got %v", up)
+	assert(t.Errorf, Equal(down, Lst(Sym("+"), Num(3), Num(7))), "expected TransformDown to give (+ 3 7), got %v", down)
+}
+
+func TestTransformDownSeesTheResultOfItsParent(t *testing.T) {
+	// expand replaces x with (y x) and y with z.
+	expand := func(t Tree) Tree {
+		switch {
+		case Equal(t, Sym("x")):
+			return Lst(Sym("y"), Sym("done"))
+		case Equal(t, Sym("y")):
+			return Sym("z")
+		}
+		return t
+	}
+
+	result := TransformDown(Lst(Sym("x")), expand)
+
+	expected := Lst(Lst(Sym("z"), Sym("done")))
+	assert(t.Errorf, Equal(result, expected), "expected %v, got %v", expected, result)
+}
+
+func TestTransformKeepsUnchangedSubtrees(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("((a b) (c d))"))
+	dec.RecordSpans("")
+	tree, _ := dec.Decode()
+	renameC := func(t Tree) Tree {
+		if Equal(t, Sym("c")) {
+			return Sym("x")
+		}
+		return t
+	}
+
+	for name, transform := range map[string]func(Tree, func(Tree) Tree) Tree{"up": TransformUp, "down": TransformDown} {
+		result := transform(tree, renameC)
+
+		var b bytes.Buffer
+		WriteSexpr(&b, result)
+		assert(t.Errorf, b.String() == "((a b) (x d))", "%s: expected ((a b) (x d)), got %s", name, b.String())
+		result.IfList(func(l List) {
+			tree.IfList(func(orig List) {
+				assert(t.Errorf, identical(l.At(0), orig.At(0)), "%s: the unchanged subtree was rebuilt", name)
+			})
+			_, hasSpan := l.At(1).Span()
+			assert(t.Errorf, !hasSpan, "%s: the changed subtree kept its span", name)
+		})
+
+		same := transform(tree, func(t Tree) Tree { return t })
+		assert(t.Errorf, identical(same, tree), "%s: an identity transform rebuilt the tree", name)
+	}
+}
+
+func TestTraversalsVisitTails(t *testing.T) {
+	tree := DottedLst([]Tree{Sym("a"), Sym("b")}, Sym("c"))
+
+	var visited []string
+	Walk(tree, func(t Tree) WalkAction {
+		t.IfSymbol(func(name string) { visited = append(visited, name) })
+		return Continue
+	}, nil)
+	count := Fold(tree, func(_ Tree, elems []int) int { return len(elems) })
+	upper := TransformUp(tree, func(t Tree) Tree {
+		if Equal(t, Sym("c")) {
+			return Sym("C")
+		}
+		return t
+	})
+	var paths []string
+	for p := range Subtrees(tree) {
+		paths = append(paths, p.String())
+	}
+
+	assert(t.Errorf, slices.Equal(visited, []string{"a", "b", "c"}), "expected to visit a, b and c, visited %v", visited)
+	assert(t.Errorf, count == 3, "expected Fold to pass 3 values, got %d", count)
+	assert(t.Errorf, sexpr{upper}.String() == "(a b . C)", "expected (a b . C), got %v", upper)
+	assert(t.Errorf, slices.Equal(paths, []string{"/", "/0", "/1", "/2"}), "expected paths /, /0, /1 and /2, got %v", paths)
+}
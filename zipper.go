@@ -0,0 +1,177 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import "slices"
+
+// A Zipper points at a subtree of a Tree, called its focus.
+// It can move around the Tree and edit it near the focus.
+//
+// Like Trees, Zippers are immutable.
+// Each move or edit returns a new Zipper, leaving the receiver as it was.
+// The methods that move the focus also report whether the move was possible.
+// When it wasn't, they return the receiver unchanged.
+//
+// Edits don't copy the whole Tree.
+// The ancestors of the focus get rebuilt as the Zipper moves up towards the root,
+// while the subtrees that weren't edited are shared with the original Tree.
+// Rebuilt lists don't keep their spans.
+//
+// The tail of an improper list counts as an element after the last one.
+// Replacing it with a list splices the elements of that list in,
+// and removing it makes the list proper.
+type Zipper struct {
+	focus   Tree
+	changed bool
+	parent  *zipperFrame
+}
+
+// A zipperFrame remembers the list a Zipper has moved down into.
+// The element at the index might be out of date, as the focus of the Zipper replaces it.
+type zipperFrame struct {
+	list    Tree
+	index   int
+	changed bool
+	parent  *zipperFrame
+}
+
+// NewZipper creates a Zipper focused on the root of the Tree.
+func NewZipper(t Tree) Zipper {
+	return Zipper{focus: t}
+}
+
+// Focus returns the subtree the Zipper points at.
+func (z Zipper) Focus() Tree { return z.focus }
+
+// IsRoot tells whether the focus is the root of the Tree.
+func (z Zipper) IsRoot() bool { return z.parent == nil }
+
+// Index returns the position of the focus in the list containing it.
+// It is -1 at the root.
+func (z Zipper) Index() int {
+	if z.parent == nil {
+		return -1
+	}
+	return z.parent.index
+}
+
+// Path returns the Path from the root to the focus.
+func (z Zipper) Path() Path {
+	p := Path{}
+	for frame := z.parent; frame != nil; frame = frame.parent {
+		p = append(p, frame.index)
+	}
+	slices.Reverse(p)
+	return p
+}
+
+// Root returns the whole Tree, with all the edits made so far.
+func (z Zipper) Root() Tree {
+	for !z.IsRoot() {
+		z, _ = z.Up()
+	}
+	return z.focus
+}
+
+// Down moves the focus to the i-th element of the list in focus.
+// It fails if the focus is not a list or has no such element.
+func (z Zipper) Down(i int) (Zipper, bool) {
+	if z.focus.tag != symTreeList || i < 0 || i >= z.focus.list.extent() {
+		return z, false
+	}
+	frame := &zipperFrame{list: z.focus, index: i, changed: z.changed, parent: z.parent}
+	return Zipper{focus: z.focus.list.elem(i), parent: frame}, true
+}
+
+// Up moves the focus to the list containing it.
+// It fails at the root.
+func (z Zipper) Up() (Zipper, bool) {
+	if z.parent == nil {
+		return z, false
+	}
+	frame := z.syncedFrame()
+	return Zipper{focus: frame.list, changed: frame.changed, parent: frame.parent}, true
+}
+
+// Left moves the focus to the element before it.
+// It fails when there is none.
+func (z Zipper) Left() (Zipper, bool) {
+	return z.sideways(-1)
+}
+
+// Right moves the focus to the element after it.
+// It fails when there is none.
+func (z Zipper) Right() (Zipper, bool) {
+	return z.sideways(+1)
+}
+
+func (z Zipper) sideways(step int) (Zipper, bool) {
+	if z.parent == nil {
+		return z, false
+	}
+	i := z.parent.index + step
+	frame := *z.syncedFrame()
+	if i < 0 || i >= frame.list.list.extent() {
+		return z, false
+	}
+	frame.index = i
+	return Zipper{focus: frame.list.list.elem(i), parent: &frame}, true
+}
+
+// Replace puts t in place of the focus.
+// The focus is then t.
+func (z Zipper) Replace(t Tree) Zipper {
+	return Zipper{focus: t, changed: true, parent: z.parent}
+}
+
+// InsertBefore puts t into the list containing the focus, right before the focus.
+// The focus stays where it was.
+// It fails at the root.
+func (z Zipper) InsertBefore(t Tree) (Zipper, bool) {
+	return z.insert(0, t)
+}
+
+// InsertAfter puts t into the list containing the focus, right after the focus.
+// The focus stays where it was.
+// It fails at the root and at the tail of an improper list.
+func (z Zipper) InsertAfter(t Tree) (Zipper, bool) {
+	return z.insert(1, t)
+}
+
+func (z Zipper) insert(offset int, t Tree) (Zipper, bool) {
+	if z.parent == nil || z.parent.index+offset > z.parent.list.list.Len() {
+		return z, false
+	}
+	frame := *z.parent
+	frame.list = frame.list.list.Insert(frame.index+offset, t).Tree()
+	frame.changed = true
+	if offset == 0 {
+		frame.index++
+	}
+	return Zipper{focus: z.focus, changed: z.changed, parent: &frame}, true
+}
+
+// Remove takes the focus out of the list containing it.
+// The focus moves up to that list.
+// It fails at the root.
+func (z Zipper) Remove() (Zipper, bool) {
+	if z.parent == nil {
+		return z, false
+	}
+	frame := z.parent
+	list := frame.list.list.deleteElem(frame.index).Tree()
+	return Zipper{focus: list, changed: true, parent: frame.parent}, true
+}
+
+// syncedFrame returns the parent frame, with the focus put back into its list if it was edited.
+func (z Zipper) syncedFrame() *zipperFrame {
+	if !z.changed {
+		return z.parent
+	}
+	frame := *z.parent
+	frame.list = frame.list.list.setElem(frame.index, z.focus).Tree()
+	frame.changed = true
+	return &frame
+}
@@ -0,0 +1,178 @@
+//   This Source Code Form is subject to the terms of the Mozilla Public
+//   License, v. 2.0. If a copy of the MPL was not distributed with this
+//   file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package symtree
+
+import (
+	"strings"
+	"testing"
+)
+
+// zipperStep is one move or edit of a Zipper, failing when the Zipper can't make it.
+type zipperStep func(Zipper) (Zipper, bool)
+
+func down(i int) zipperStep          { return func(z Zipper) (Zipper, bool) { return z.Down(i) } }
+func up(z Zipper) (Zipper, bool)     { return z.Up() }
+func left(z Zipper) (Zipper, bool)   { return z.Left() }
+func right(z Zipper) (Zipper, bool)  { return z.Right() }
+func remove(z Zipper) (Zipper, bool) { return z.Remove() }
+
+func replace(t Tree) zipperStep {
+	return func(z Zipper) (Zipper, bool) { return z.Replace(t), true }
+}
+
+func insertBefore(t Tree) zipperStep {
+	return func(z Zipper) (Zipper, bool) { return z.InsertBefore(t) }
+}
+
+func insertAfter(t Tree) zipperStep {
+	return func(z Zipper) (Zipper, bool) { return z.InsertAfter(t) }
+}
+
+func TestZipperEdits(t *testing.T) {
+	tree := Lst(Sym("+"), Lst(Sym("*"), Num(2), Num(3)), Num(4))
+	cases := map[string]struct {
+		steps       []zipperStep
+		focus, root Tree
+	}{
+		"nothing": {
+			nil,
+			tree, tree,
+		},
+		"down": {
+			[]zipperStep{down(1), down(2)},
+			Num(3), tree,
+		},
+		"downAndUp": {
+			[]zipperStep{down(1), down(2), up},
+			Lst(Sym("*"), Num(2), Num(3)), tree,
+		},
+		"sideways": {
+			[]zipperStep{down(0), right, right, left},
+			Lst(Sym("*"), Num(2), Num(3)), tree,
+		},
+		"replace": {
+			[]zipperStep{down(1), down(2), replace(Sym("x"))},
+			Sym("x"), Lst(Sym("+"), Lst(Sym("*"), Num(2), Sym("x")), Num(4)),
+		},
+		"replaceAndMoveOn": {
+			[]zipperStep{down(1), down(1), replace(Num(5)), right, replace(Num(6)), up, right},
+			Num(4), Lst(Sym("+"), Lst(Sym("*"), Num(5), Num(6)), Num(4)),
+		},
+		"replaceRoot": {
+			[]zipperStep{replace(Sym("x"))},
+			Sym("x"), Sym("x"),
+		},
+		"insertBefore": {
+			[]zipperStep{down(2), insertBefore(Num(1)), left},
+			Num(1), Lst(Sym("+"), Lst(Sym("*"), Num(2), Num(3)), Num(1), Num(4)),
+		},
+		"insertAfter": {
+			[]zipperStep{down(1), insertAfter(Num(1)), right},
+			Num(1), Lst(Sym("+"), Lst(Sym("*"), Num(2), Num(3)), Num(1), Num(4)),
+		},
+		"editThenInsert": {
+			[]zipperStep{down(2), replace(Num(5)), insertBefore(Num(1)), insertAfter(Num(9))},
+			Num(5), Lst(Sym("+"), Lst(Sym("*"), Num(2), Num(3)), Num(1), Num(5), Num(9)),
+		},
+		"remove": {
+			[]zipperStep{down(1), down(0), remove},
+			Lst(Num(2), Num(3)), Lst(Sym("+"), Lst(Num(2), Num(3)), Num(4)),
+		},
+	}
+	for name, c := range cases {
+		z := NewZipper(tree)
+		for i, step := range c.steps {
+			var ok bool
+			if z, ok = step(z); !ok {
+				t.Errorf("%s: step %d failed", name, i)
+			}
+		}
+		assert(t.Errorf, Equal(z.Focus(), c.focus), "%s: expected focus %v, got %v", name, c.focus, z.Focus())
+		assert(t.Errorf, Equal(z.Root(), c.root), "%s: expected root %v, got %v", name, c.root, z.Root())
+	}
+}
+
+func TestZipperMovesFail(t *testing.T) {
+	tree := Lst(Sym("a"), Lst())
+	cases := map[string][]zipperStep{
+		"upFromRoot":         {up},
+		"leftFromRoot":       {left},
+		"removeRoot":         {remove},
+		"insertAtRoot":       {insertBefore(Num(1))},
+		"downOutOfRange":     {down(2)},
+		"downNegative":       {down(-1)},
+		"downIntoAtom":       {down(0), down(0)},
+		"downIntoEmptyList":  {down(1), down(0)},
+		"leftOfFirstElement": {down(0), left},
+		"rightOfLastElement": {down(1), right},
+	}
+	for name, steps := range cases {
+		z, ok := NewZipper(tree), true
+		for _, step := range steps {
+			before := z
+			z, ok = step(z)
+			if !ok {
+				assert(t.Errorf, Equal(z.Focus(), before.Focus()), "%s: a failed move changed the focus", name)
+			}
+		}
+		assert(t.Errorf, !ok, "%s: the last step should have failed", name)
+	}
+}
+
+func TestZipperKeepsUneditedSubtrees(t *testing.T) {
+	dec := NewDecoder(strings.NewReader("((a b) (c d))"))
+	dec.RecordSpans("")
+	tree, _ := dec.Decode()
+
+	z, _ := NewZipper(tree).Down(1)
+	z, _ = z.Down(0)
+	root := z.Replace(Sym("x")).Root()
+
+	var kept, rebuilt bool
+	root.IfList(func(l List) {
+		_, kept = l.At(0).Span()
+		_, rebuilt = l.At(1).Span()
+	})
+	assert(t.Errorf, kept, "the unedited subtree lost its span")
+	assert(t.Errorf, !rebuilt, "the edited subtree kept its span")
+
+	z, _ = NewZipper(tree).Down(1)
+	_, hasSpan := z.Root().Span()
+	assert(t.Errorf, hasSpan, "moving around without editing lost the span of the root")
+}
+
+func TestZipperOnImproperLists(t *testing.T) {
+	tree := DottedLst([]Tree{Sym("a"), Sym("b")}, Sym("c"))
+	cases := map[string]struct {
+		steps []zipperStep
+		focus Tree
+		root  string
+	}{
+		"downToTail":        {[]zipperStep{down(2)}, Sym("c"), "(a b . c)"},
+		"rightToTail":       {[]zipperStep{down(1), right}, Sym("c"), "(a b . c)"},
+		"replaceTail":       {[]zipperStep{down(2), replace(Num(1))}, Num(1), "(a b . 1)"},
+		"replaceTailByList": {[]zipperStep{down(2), replace(Lst(Sym("c"), Sym("d")))}, Lst(Sym("c"), Sym("d")), "(a b c d)"},
+		"removeTail":        {[]zipperStep{down(2), remove}, Lst(Sym("a"), Sym("b")), "(a b)"},
+		"insertBeforeTail":  {[]zipperStep{down(2), insertBefore(Sym("x")), left}, Sym("x"), "(a b x . c)"},
+	}
+	for name, c := range cases {
+		z := NewZipper(tree)
+		for i, step := range c.steps {
+			var ok bool
+			if z, ok = step(z); !ok {
+				t.Errorf("%s: step %d failed", name, i)
+			}
+		}
+		assert(t.Errorf, Equal(z.Focus(), c.focus), "%s: expected focus %v, got %v", name, c.focus, z.Focus())
+		root := sexpr{z.Root()}.String()
+		assert(t.Errorf, root == c.root, "%s: expected root %s, got %s", name, c.root, root)
+	}
+
+	z, _ := NewZipper(tree).Down(2)
+	_, ok := z.InsertAfter(Sym("x"))
+	assert(t.Errorf, !ok, "inserting after the tail should fail")
+	_, ok = z.Right()
+	assert(t.Errorf, !ok, "there should be nothing right of the tail")
+}